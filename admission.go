@@ -0,0 +1,119 @@
+package signalr
+
+import (
+	"net"
+	"sync"
+)
+
+// admissionPolicy limits the connections a server serves at the same time
+type admissionPolicy struct {
+	mx             sync.Mutex
+	maxConnections int
+	maxPerAddress  int
+	maxPerUser     int
+	userID         func(request *RequestFeatures) string
+	admit          func(request *RequestFeatures) error
+	connections    int
+	perAddress     map[string]int
+	perUser        map[string]int
+}
+
+// admissionError is the reason why a connection is rejected. status is the http status code for the rejection
+type admissionError struct {
+	status int
+	text   string
+}
+
+func (a *admissionError) Error() string {
+	return a.text
+}
+
+// check checks if a connection for request would be admitted, without counting it.
+// If not, it returns the http status code and the reason.
+func (a *admissionPolicy) check(request *RequestFeatures) (status int, err error) {
+	if err = a.checkHook(request); err != nil {
+		return err.(*admissionError).status, err
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if err = a.checkLimits(remoteHost(request.RemoteAddr()), a.user(request)); err != nil {
+		return err.(*admissionError).status, err
+	}
+	return 200, nil
+}
+
+// acquire counts a connection for request if it is admitted. release must be called when the connection has ended.
+// release is a no-op when the connection was not admitted.
+func (a *admissionPolicy) acquire(request *RequestFeatures) (release func(), err error) {
+	release = func() {}
+	if err = a.checkHook(request); err != nil {
+		return release, err
+	}
+	address, user := remoteHost(request.RemoteAddr()), a.user(request)
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if err = a.checkLimits(address, user); err != nil {
+		return release, err
+	}
+	a.connections++
+	if address != "" {
+		a.perAddress[address]++
+	}
+	if user != "" {
+		a.perUser[user]++
+	}
+	return func() {
+		a.mx.Lock()
+		defer a.mx.Unlock()
+		a.connections--
+		decrement(a.perAddress, address)
+		decrement(a.perUser, user)
+	}, nil
+}
+
+func (a *admissionPolicy) checkHook(request *RequestFeatures) error {
+	if a.admit != nil {
+		if err := a.admit(request); err != nil {
+			return &admissionError{status: 403, text: err.Error()} // Forbidden
+		}
+	}
+	return nil
+}
+
+// checkLimits checks the connection limits. a.mx must be locked
+func (a *admissionPolicy) checkLimits(address string, user string) error {
+	if a.maxConnections > 0 && a.connections >= a.maxConnections {
+		return &admissionError{status: 503, text: "maximum number of connections reached"} // Service unavailable
+	}
+	if a.maxPerAddress > 0 && address != "" && a.perAddress[address] >= a.maxPerAddress {
+		return &admissionError{status: 429, text: "maximum number of connections per address reached"} // Too many requests
+	}
+	if a.maxPerUser > 0 && user != "" && a.perUser[user] >= a.maxPerUser {
+		return &admissionError{status: 429, text: "maximum number of connections per user reached"} // Too many requests
+	}
+	return nil
+}
+
+func (a *admissionPolicy) user(request *RequestFeatures) string {
+	if a.userID == nil {
+		return ""
+	}
+	return a.userID(request)
+}
+
+func decrement(counts map[string]int, key string) {
+	if key == "" {
+		return
+	}
+	if counts[key]--; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// remoteHost returns the host part of remoteAddr
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,123 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"os"
+	"time"
+)
+
+// serveTestingConnection serves a new testingConnection and returns it with the handshake response of the server
+func serveTestingConnection(server Server) (*testingConnection, string) {
+	conn := newTestingConnection()
+	conn.ClientSend(`{"protocol": "json","version": 1}`)
+	go server.ServeConnection(conn)
+	response, err := conn.ClientReceive()
+	Expect(err).NotTo(HaveOccurred())
+	return conn, response
+}
+
+var _ = Describe("Admission", func() {
+	Context("When the maximum number of connections is reached", func() {
+		It("should reject further connections with an error handshake response until a connection ends", func(done Done) {
+			server, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MaxConnections(1),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			conn, response := serveTestingConnection(server)
+			Expect(response).To(Equal("{}"))
+			_, response = serveTestingConnection(server)
+			Expect(response).To(ContainSubstring("maximum number of connections reached"))
+			conn.ClientSend(`{"type":7}`)
+			Eventually(func() string {
+				_, response := serveTestingConnection(server)
+				return response
+			}).Should(Equal("{}"))
+			close(done)
+		}, 2.0)
+	})
+	Context("When connecting over http", func() {
+		var httpServer *httptest.Server
+		var cancel context.CancelFunc
+		startServer := func(options ...func(Party) error) {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			server, err := NewServer(ctx, append([]func(Party) error{
+				SimpleHubFactory(&addHub{}),
+				HTTPTransports("WebSockets"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+			Expect(err).NotTo(HaveOccurred())
+			httpServer = httptest.NewServer(server.Handler())
+		}
+		startClient := func(query string, options ...func(Party) error) {
+			client, err := NewHTTPClient(context.TODO(), httpServer.URL+query, append([]func(Party) error{
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Start()).To(Succeed())
+			result := <-client.Invoke("Add2", 1)
+			Expect(result.Error).NotTo(HaveOccurred())
+		}
+		AfterEach(func() {
+			cancel()
+			httpServer.Close()
+		})
+		It("should reject negotiations with 429 when the limit per address is reached", func(done Done) {
+			startServer(MaxConnectionsPerAddress(1))
+			startClient("")
+			Expect(negotiateStatus(httpServer.URL, "")).To(Equal(429))
+			close(done)
+		}, 2.0)
+		It("should admit connections with stateful reconnect by their address", func(done Done) {
+			addresses := make(chan string, 10)
+			startServer(MaxConnectionsPerAddress(1), StatefulReconnect(1<<16, time.Second),
+				Admit(func(request *RequestFeatures) error {
+					addresses <- request.RemoteAddr()
+					return nil
+				}))
+			startClient("", StatefulReconnect(1<<16, time.Second))
+			// Admit is called for the negotiate request, the connect request and when the connection starts
+			for i := 0; i < 3; i++ {
+				Expect(<-addresses).NotTo(BeEmpty())
+			}
+			Expect(negotiateStatus(httpServer.URL, "")).To(Equal(429))
+			close(done)
+		}, 2.0)
+		It("should reject negotiations with 503 when the maximum number of connections is reached", func(done Done) {
+			startServer(MaxConnections(1))
+			startClient("")
+			Expect(negotiateStatus(httpServer.URL, "")).To(Equal(503))
+			close(done)
+		}, 2.0)
+		It("should reject negotiations of the same user with 429 when the limit per user is reached", func(done Done) {
+			startServer(MaxConnectionsPerUser(1, func(request *RequestFeatures) string {
+				return request.Query().Get("user")
+			}))
+			startClient("?user=a")
+			Expect(negotiateStatus(httpServer.URL, "?user=a")).To(Equal(429))
+			Expect(negotiateStatus(httpServer.URL, "?user=b")).To(Equal(200))
+			close(done)
+		}, 2.0)
+		It("should reject negotiations with 403 when Admit returns an error", func(done Done) {
+			startServer(Admit(func(request *RequestFeatures) error {
+				if request.Query().Get("key") != "secret" {
+					return errors.New("invalid key")
+				}
+				return nil
+			}))
+			Expect(negotiateStatus(httpServer.URL, "")).To(Equal(403))
+			Expect(negotiateStatus(httpServer.URL, "?key=secret")).To(Equal(200))
+			close(done)
+		}, 2.0)
+	})
+	Context("When the options are invalid", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MaxConnections(-1))
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MaxConnectionsPerUser(1, nil))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
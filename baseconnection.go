@@ -6,9 +6,10 @@ import (
 )
 
 type baseConnection struct {
-	ctx          context.Context
-	connectionID string
-	timeout      time.Duration
+	ctx             context.Context
+	connectionID    string
+	timeout         time.Duration
+	requestFeatures *RequestFeatures
 }
 
 func (b *baseConnection) Context() context.Context {
@@ -19,6 +20,13 @@ func (b *baseConnection) ConnectionID() string {
 	return b.connectionID
 }
 
+func (b *baseConnection) RequestFeatures() *RequestFeatures {
+	if b.requestFeatures == nil {
+		return emptyRequestFeatures
+	}
+	return b.requestFeatures
+}
+
 func (b *baseConnection) SetTimeout(duration time.Duration) {
 	b.timeout = duration
 }
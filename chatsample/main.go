@@ -94,29 +94,15 @@ func (c *chat) UploadStream(upload1 <-chan int, factor float64, upload2 <-chan f
 	}
 }
 
-//func runTCPServer(address string, hub signalr.HubInterface) {
-//	listener, err := net.Listen("tcp", address)
-//
-//	if err != nil {
-//		fmt.Println(err)
-//		return
-//	}
-//
-//	fmt.Printf("Listening for TCP connection on %s\n", listener.Addr())
-//
-//	server, _ := signalr.NewServer(context.TODO(), signalr.UseHub(hub))
-//
-//	for {
-//		conn, err := listener.Accept()
-//
-//		if err != nil {
-//			fmt.Println(err)
-//			break
-//		}
-//
-//		go server.ServeConnection(context.TODO(), newNetConnection(conn))
-//	}
-//}
+func runTCPServer(address string, hub signalr.HubInterface) {
+	server, _ := signalr.NewServer(context.TODO(), signalr.SimpleHubFactory(hub),
+		signalr.Logger(kitlog.NewLogfmtLogger(os.Stderr), true))
+
+	fmt.Printf("Listening for TCP connections on %s\n", address)
+	if err := server.ListenAndServeTCP(address); err != nil {
+		log.Fatal("ListenAndServeTCP:", err)
+	}
+}
 
 func runHTTPServer(address string, hub signalr.HubInterface) {
 	server, _ := signalr.NewServer(context.TODO(), signalr.SimpleHubFactory(hub),
@@ -148,7 +134,7 @@ func (c *client) Receive(msg string) {
 func main() {
 	hub := &chat{}
 
-	//go runTCPServer("127.0.0.1:8007", hub)
+	go runTCPServer("127.0.0.1:8007", hub)
 	go runHTTPServer("localhost:8086", hub)
 	//<-time.After(time.Millisecond * 2)
 	//go runHTTPClient("http://localhost:8086/chat", &client{})
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-kit/kit/log"
-	"github.com/mailru/easyjson/jwriter"
 	"os"
 	"reflect"
 	"sync"
@@ -24,7 +23,8 @@ type Client interface {
 	PullStream(method string, arguments ...interface{}) <-chan InvokeResult
 	PushStreams(method string, arguments ...interface{}) <-chan error
 	// It is not necessary to register callbacks with On(...),
-	// the server can "call back" all exported methods of the receiver
+	// the server can "call back" all exported methods of the receiver.
+	// If the methods of the receiver can not be used for invocations, Start returns an error
 	SetReceiver(receiver interface{})
 }
 
@@ -36,6 +36,7 @@ func NewClient(ctx context.Context, conn Connection, options ...func(Party) erro
 		conn:      conn,
 		partyBase: newPartyBase(ctx, info, dbg),
 		lastID:    -1,
+		methods:   &methodTable{},
 	}
 	for _, option := range options {
 		if option != nil {
@@ -44,28 +45,45 @@ func NewClient(ctx context.Context, conn Connection, options ...func(Party) erro
 			}
 		}
 	}
+	if *c.protocols() == nil {
+		*c.protocols() = []string{"json"}
+	}
 	return c, nil
 }
 
 type client struct {
 	partyBase
-	conn      Connection
-	loop      *loop
-	receiver  interface{}
-	lastID    int64
-	loopMx    sync.Mutex
-	loopEnded bool
+	conn       Connection
+	loop       *loop
+	receiver   interface{}
+	methods    *methodTable
+	methodsErr error
+	lastID     int64
+	loopMx     sync.Mutex
+	loopEnded  bool
 }
 
 func (c *client) Start() error {
-	protocol, err := c.processHandshake()
+	// The receiver can not be used for invocations from the server
+	if c.methodsErr != nil {
+		return c.methodsErr
+	}
+	protocol, version, received, err := c.processHandshake()
 	if err != nil {
 		return err
 	}
-	c.loop = newLoop(c, c.conn, protocol)
+	if resumable, ok := c.conn.(*resumableConnection); ok && version < statefulReconnectVersion {
+		// Without Ack and Sequence messages, the connection can not be resumed
+		resumable.disable()
+	}
+	c.loop = newLoop(c, c.conn, protocol, received)
 	started := make(chan struct{}, 1)
 	go func(c *client, started chan struct{}) {
 		c.loop.Run(started)
+		if resumable, ok := c.conn.(*resumableConnection); ok {
+			// Do not reconnect after the connection has ended
+			resumable.close()
+		}
 		c.loopMx.Lock()
 		c.loopEnded = true
 		c.loopMx.Unlock()
@@ -163,6 +181,11 @@ func (c *client) PushStreams(method string, arguments ...interface{}) <-chan err
 
 func (c *client) SetReceiver(receiver interface{}) {
 	c.receiver = receiver
+	c.methods, c.methodsErr = newMethodTable(reflect.TypeOf(receiver))
+	if c.methodsErr != nil {
+		info, _ := c.prefixLoggers(c.conn.ConnectionID())
+		_ = info.Log(evt, "SetReceiver", "error", c.methodsErr)
+	}
 }
 
 // GetNewID returns a new, connection-unique id for invocations and streams
@@ -200,6 +223,15 @@ func (c *client) invocationTarget(hubConnection) interface{} {
 	return c.receiver
 }
 
+func (c *client) invocationMethod(name string) (*hubMethod, bool) {
+	return c.methods.method(name)
+}
+
+// newConnectionHubContext is not used, because clients can not register handlers
+func (c *client) newConnectionHubContext(hubConnection) HubContext {
+	return nil
+}
+
 func (c *client) allowReconnect() bool {
 	return false // Servers don't care?
 }
@@ -226,13 +258,24 @@ func (c *client) prefixLoggers(connectionID string) (info StructuredLogger, dbg
 			"hub", t)
 }
 
-func (c *client) processHandshake() (HubProtocol, error) {
+// processHandshake returns the protocol, its version and the data received after the handshake response
+func (c *client) processHandshake() (HubProtocol, int, []byte, error) {
 	info, dbg := c.prefixLoggers(c.conn.ConnectionID())
-	const request = "{\"protocol\":\"json\",\"version\":1}\u001e"
-	_, err := c.conn.Write([]byte(request))
+	// Stateful reconnect needs a protocol version with Ack and Sequence messages
+	_, stateful := c.conn.(*resumableConnection)
+	name, version, protocol, err := preferredProtocol(c.conn, *c.protocols(), stateful)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	rawRequest, err := json.Marshal(handshakeRequest{Protocol: name, Version: version})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	request := string(rawRequest) + "\u001e"
+	_, err = c.conn.Write([]byte(request))
 	if err != nil {
 		_ = info.Log(evt, "handshake sent", "msg", request, "error", err)
-		return nil, err
+		return nil, 0, nil, err
 	}
 	_ = dbg.Log(evt, "handshake sent", "msg", request)
 	var buf bytes.Buffer
@@ -248,26 +291,22 @@ loop:
 			var rawHandshake []byte
 			if rawHandshake, err = parseTextMessageFormat(&buf); err != nil {
 				// Partial message, read more data
-				buf.Write(data[:n])
+				continue
+			}
+			response := handshakeResponse{}
+			if err = json.Unmarshal(rawHandshake, &response); err != nil {
+				// Malformed handshake
+				_ = info.Log(evt, "handshake received", "msg", string(rawHandshake), "error", err)
 			} else {
-				response := handshakeResponse{}
-				if err = json.Unmarshal(rawHandshake, &response); err != nil {
-					// Malformed handshake
-					_ = info.Log(evt, "handshake received", "msg", string(rawHandshake), "error", err)
-				} else {
 
-					if response.Error != "" {
-						_ = info.Log(evt, "handshake received", "error", response.Error)
-						return nil, errors.New(response.Error)
-					}
-					_ = dbg.Log(evt, "handshake received", "msg", fmtMsg(response))
-					protocol := &JSONHubProtocol{easyWriter: jwriter.Writer{}}
-					_, pDbg := c.loggers()
-					protocol.setDebugLogger(pDbg)
-					return protocol, nil
+				if response.Error != "" {
+					_ = info.Log(evt, "handshake received", "error", response.Error)
+					return nil, 0, nil, errors.New(response.Error)
 				}
+				_ = dbg.Log(evt, "handshake received", "msg", fmtMsg(response))
+				return protocol, version, buf.Bytes(), nil
 			}
 		}
 	}
-	return nil, err
+	return nil, 0, nil, err
 }
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +18,7 @@ type pipeConnection struct {
 	reader  io.Reader
 	writer  io.Writer
 	timeout time.Duration
+	mx      sync.Mutex
 	fail    error
 }
 
@@ -25,19 +27,32 @@ func (pc *pipeConnection) Context() context.Context {
 }
 
 func (pc *pipeConnection) Read(p []byte) (n int, err error) {
-	if pc.fail != nil {
-		return 0, pc.fail
+	if err := pc.failure(); err != nil {
+		return 0, err
 	}
 	return pc.reader.Read(p)
 }
 
 func (pc *pipeConnection) Write(p []byte) (n int, err error) {
-	if pc.fail != nil {
-		return 0, pc.fail
+	if err := pc.failure(); err != nil {
+		return 0, err
 	}
 	return pc.writer.Write(p)
 }
 
+// setFail lets all following reads and writes fail with err
+func (pc *pipeConnection) setFail(err error) {
+	pc.mx.Lock()
+	defer pc.mx.Unlock()
+	pc.fail = err
+}
+
+func (pc *pipeConnection) failure() error {
+	pc.mx.Lock()
+	defer pc.mx.Unlock()
+	return pc.fail
+}
+
 func (pc *pipeConnection) ConnectionID() string {
 	return "X"
 }
@@ -68,7 +83,8 @@ type simpleHub struct {
 	Hub
 	receiveStreamArg        string
 	receiveStreamChanValues []int
-	receiveStreamDone       chan struct{}
+	// receiveStreamDone receives the arg of ReceiveStream when the stream has ended
+	receiveStreamDone chan string
 }
 
 func (s *simpleHub) InvokeMe(arg1 string, arg2 int) string {
@@ -94,22 +110,35 @@ func (s *simpleHub) ReadStream() chan string {
 func (s *simpleHub) ReceiveStream(arg string, ch <-chan int) {
 	s.receiveStreamArg = arg
 	s.receiveStreamChanValues = make([]int, 0)
-	go func(ch <-chan int, done chan struct{}) {
+	go func(ch <-chan int, done chan string) {
 		for v := range ch {
 			s.receiveStreamChanValues = append(s.receiveStreamChanValues, v)
 		}
-		done <- struct{}{}
+		done <- s.receiveStreamArg
 	}(ch, s.receiveStreamDone)
 }
 
 type simpleReceiver struct {
+	mx     sync.Mutex
 	result string
 }
 
 func (s *simpleReceiver) OnCallback(result string) {
+	s.setResult(result)
+}
+
+func (s *simpleReceiver) setResult(result string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
 	s.result = result
 }
 
+func (s *simpleReceiver) getResult() string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.result
+}
+
 var _ = Describe("Client", func() {
 	Context("Start", func() {
 		It("should connect to the server", func(done Done) {
@@ -154,7 +183,7 @@ var _ = Describe("Client", func() {
 			// Create the Client
 			client, _ = NewClient(context.TODO(), cliConn)
 			// Start it
-			client.SetReceiver(simpleReceiver{})
+			client.SetReceiver(&simpleReceiver{})
 			_ = client.Start()
 			close(done)
 		}, 2.0)
@@ -183,7 +212,7 @@ var _ = Describe("Client", func() {
 			close(done)
 		}, 2.0)
 		It("should return an error when the connection fails", func(done Done) {
-			cliConn.fail = errors.New("fail")
+			cliConn.setFail(errors.New("fail"))
 			r := <-client.Invoke("InvokeMe", "A", 1)
 			Expect(r.Error).To(HaveOccurred())
 			close(done)
@@ -219,17 +248,17 @@ var _ = Describe("Client", func() {
 		}, 2.0)
 
 		It("should invoke a server method and get the result via callback", func(done Done) {
-			receiver.result = ""
+			receiver.setResult("")
 			errCh := client.Send("Callback", "low")
 			ch := make(chan string, 1)
-			go func() {
+			go func(receiver *simpleReceiver) {
 				for {
-					if receiver.result != "" {
-						ch <- receiver.result
+					if result := receiver.getResult(); result != "" {
+						ch <- result
 						break
 					}
 				}
-			}()
+			}(receiver)
 			select {
 			case val := <-ch:
 				Expect(val).To(Equal("LOW"))
@@ -239,17 +268,17 @@ var _ = Describe("Client", func() {
 			close(done)
 		}, 2.0)
 		It("should invoke a server method and return the error when arguments don't match", func(done Done) {
-			receiver.result = ""
+			receiver.setResult("")
 			errCh := client.Send("Callback", 1)
 			ch := make(chan string, 1)
-			go func() {
+			go func(receiver *simpleReceiver) {
 				for {
-					if receiver.result != "" {
-						ch <- receiver.result
+					if result := receiver.getResult(); result != "" {
+						ch <- result
 						break
 					}
 				}
-			}()
+			}(receiver)
 			select {
 			case <-ch:
 				Fail("Value should not be returned")
@@ -257,11 +286,11 @@ var _ = Describe("Client", func() {
 				Expect(err).To(HaveOccurred())
 			}
 			// Stop the above go func
-			receiver.result = "Stop"
+			receiver.setResult("Stop")
 			close(done)
 		}, 2.0)
 		It("should return an error when the connection fails", func(done Done) {
-			cliConn.fail = errors.New("fail")
+			cliConn.setFail(errors.New("fail"))
 			err := <-client.Send("Callback", 1)
 			Expect(err).To(HaveOccurred())
 			close(done)
@@ -327,7 +356,7 @@ var _ = Describe("Client", func() {
 			close(done)
 		}, 2.0)
 		It("should return an error when the connection fails", func(done Done) {
-			cliConn.fail = errors.New("fail")
+			cliConn.setFail(errors.New("fail"))
 			r := <-client.PullStream("ReadStream")
 			Expect(r.Error).To(HaveOccurred())
 			close(done)
@@ -351,12 +380,14 @@ var _ = Describe("Client", func() {
 		var cliConn *pipeConnection
 		var srvConn *pipeConnection
 		var client Client
-		var hub *simpleHub
+		var receiveStreamDone chan string
 		var server Server
 		BeforeEach(func(done Done) {
-			hub = &simpleHub{}
-			hub.receiveStreamDone = make(chan struct{}, 1)
-			server, _ = NewServer(context.TODO(), HubFactory(func() HubInterface { return hub }),
+			// Each invocation gets its own hub, they only share the channel
+			receiveStreamDone = make(chan string, 1)
+			streamDone := receiveStreamDone
+			server, _ = NewServer(context.TODO(),
+				HubFactory(func() HubInterface { return &simpleHub{receiveStreamDone: streamDone} }),
 				Logger(log.NewLogfmtLogger(os.Stderr), false),
 				ChanReceiveTimeout(200*time.Millisecond),
 				StreamBufferCapacity(5))
@@ -387,13 +418,12 @@ var _ = Describe("Client", func() {
 				}
 				close(ch)
 			}(ch)
-			<-hub.receiveStreamDone
-			Expect(hub.receiveStreamArg).To(Equal("test"))
+			Expect(<-receiveStreamDone).To(Equal("test"))
 			close(done)
 		})
 
 		It("should return an error when the connection fails", func(done Done) {
-			cliConn.fail = errors.New("fail")
+			cliConn.setFail(errors.New("fail"))
 			ch := make(chan int, 1)
 			err := <-client.PushStreams("ReceiveStream", "test", ch)
 			Expect(err).To(HaveOccurred())
@@ -17,14 +17,15 @@ type clientSSEConnection struct {
 	sseWriter io.Writer
 }
 
-func newClientSSEConnection(parentContext context.Context, address string, connectionID string, body io.ReadCloser) (*clientSSEConnection, error) {
+func newClientSSEConnection(parentContext context.Context, address string, connectionID string, connectionToken string,
+	body io.ReadCloser) (*clientSSEConnection, error) {
 	// Setup request
 	reqUrl, err := url.Parse(address)
 	if err != nil {
 		return nil, err
 	}
 	q := reqUrl.Query()
-	q.Set("id", connectionID)
+	q.Set("id", connectionToken)
 	reqUrl.RawQuery = q.Encode()
 	c := clientSSEConnection{
 		baseConnection: baseConnection{
@@ -44,6 +45,10 @@ func newClientSSEConnection(parentContext context.Context, address string, conne
 			}
 			lines := strings.Split(string(p[:n]), "\n")
 			for _, line := range lines {
+				// Lines starting with a colon are comments, the server sends them to keep the stream open
+				if strings.HasPrefix(line, ":") {
+					continue
+				}
 				json := strings.Replace(strings.Trim(line, "\r"), "data:", "", 1)
 				// Spec says: If it starts with Space, remove it
 				if len(json) > 0 && json[0] == ' ' {
@@ -60,6 +65,10 @@ func newClientSSEConnection(parentContext context.Context, address string, conne
 	return &c, nil
 }
 
+func (c *clientSSEConnection) setTransferFormat(format string) error {
+	return sseTransferFormat(format)
+}
+
 func (c *clientSSEConnection) Read(p []byte) (n int, err error) {
 	return c.sseReader.Read(p)
 }
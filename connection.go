@@ -15,3 +15,22 @@ type Connection interface {
 	SetTimeout(duration time.Duration)
 	Timeout() time.Duration
 }
+
+// readLimiter is implemented by Connections which can reject too large messages on the transport level
+type readLimiter interface {
+	setReadLimit(limit int64)
+}
+
+// transferFormatter is implemented by Connections which distinguish text and binary messages on the transport level.
+// setTransferFormat returns an error if the Connection can not transfer the format. Connections which do not
+// implement it transfer both formats.
+type transferFormatter interface {
+	setTransferFormat(format string) error
+}
+
+// closeCauser is implemented by Connections which tell the other side on the transport level why they are closed,
+// e.g. with the status code of a WebSocket close frame. setCloseCause must be called before the Connection is closed.
+// cause nil means that the connection ends normally.
+type closeCauser interface {
+	setCloseCause(cause error)
+}
@@ -3,6 +3,7 @@ package signalr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"time"
@@ -215,4 +216,118 @@ var _ = Describe("Handshake", func() {
 			close(done)
 		})
 	})
+	Context("When a handshake is sent with an unsupported protocol version", func() {
+		It("should return an error handshake response which names the version and be not connected", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}))
+			conn := newTestingConnection()
+			go server.ServeConnection(conn)
+			conn.ClientSend(`{"protocol": "json","version": 3}`)
+			response, err := conn.ClientReceive()
+			Expect(err).To(BeNil())
+			jsonMap := make(map[string]interface{})
+			Expect(json.Unmarshal([]byte(response), &jsonMap)).To(Succeed())
+			Expect(jsonMap["error"]).To(Equal("the server does not support version 3 of the 'json' protocol"))
+			conn.ClientSend(`{"type":1,"invocationId": "123I","target":"shake"}`)
+			select {
+			case <-shakeQueue:
+				Fail("server connected with unsupported protocol version")
+			case <-time.After(100 * time.Millisecond):
+			}
+			close(done)
+		})
+	})
+	Context("When a handshake is sent with protocol version 2", func() {
+		It("should be connected", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}))
+			conn := newTestingConnection()
+			go server.ServeConnection(conn)
+			conn.ClientSend(`{"protocol": "json","version": 2}`)
+			conn.ClientSend(`{"type":1,"invocationId": "123J","target":"shake"}`)
+			Expect(<-shakeQueue).To(Equal("Shake()"))
+			close(done)
+		})
+	})
+	Context("When AcceptHandshake is set", func() {
+		It("should pass the handshake to the hook and reject the connection with its error", func(done Done) {
+			handshakes := make(chan Handshake, 1)
+			server, err := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}),
+				AcceptHandshake(func(handshake Handshake) error {
+					handshakes <- handshake
+					return errors.New("go away")
+				}))
+			Expect(err).NotTo(HaveOccurred())
+			conn := newTestingConnection()
+			go server.ServeConnection(conn)
+			conn.ClientSend(`{"protocol": "json","version": 1}`)
+			handshake := <-handshakes
+			Expect(handshake.Protocol).To(Equal("json"))
+			Expect(handshake.Version).To(Equal(1))
+			Expect(handshake.ConnectionID).To(Equal(conn.ConnectionID()))
+			response, err := conn.ClientReceive()
+			Expect(err).To(BeNil())
+			Expect(response).To(Equal(`{"error":"go away"}`))
+			conn.ClientSend(`{"type":1,"invocationId": "123K","target":"shake"}`)
+			select {
+			case <-shakeQueue:
+				Fail("server connected with rejected handshake")
+			case <-time.After(100 * time.Millisecond):
+			}
+			close(done)
+		})
+		It("should not call the hook for unsupported protocols", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}),
+				AcceptHandshake(func(handshake Handshake) error {
+					Fail("hook called for unsupported protocol")
+					return nil
+				}))
+			conn := newTestingConnection()
+			go server.ServeConnection(conn)
+			conn.ClientSend(`{"protocol": "bson","version": 1}`)
+			response, err := conn.ClientReceive()
+			Expect(err).To(BeNil())
+			Expect(response).To(Equal(`{"error":"the protocol 'bson' is not supported"}`))
+			close(done)
+		})
+	})
+	Context("When the Protocols option is used", func() {
+		It("should not accept unknown protocols", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}), Protocols("bson"))
+			Expect(err).To(HaveOccurred())
+			_, err = NewClient(context.TODO(), nil, Protocols())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When a client starts", func() {
+		It("should request the first supported protocol with version 1", func(done Done) {
+			handshakes := make(chan Handshake, 1)
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}),
+				AcceptHandshake(func(handshake Handshake) error {
+					handshakes <- handshake
+					return nil
+				}))
+			cliConn, srvConn := newClientServerConnections()
+			go server.ServeConnection(srvConn)
+			client, err := NewClient(context.TODO(), cliConn, Protocols("json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Start()).To(Succeed())
+			handshake := <-handshakes
+			Expect(handshake.Protocol).To(Equal("json"))
+			Expect(handshake.Version).To(Equal(1))
+			Expect(client.Stop()).To(Succeed())
+			server.cancel()
+			close(done)
+		})
+		It("should return the error of the handshake response", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&handshakeHub{}),
+				AcceptHandshake(func(handshake Handshake) error {
+					return errors.New("go away")
+				}))
+			cliConn, srvConn := newClientServerConnections()
+			go server.ServeConnection(srvConn)
+			client, _ := NewClient(context.TODO(), cliConn)
+			Expect(client.Start()).To(MatchError("go away"))
+			server.cancel()
+			close(done)
+		})
+	})
 })
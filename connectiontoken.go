@@ -0,0 +1,50 @@
+package signalr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// newConnectionToken creates the secret connectionToken for connectionID. The token consists of the connectionID
+// and its HMAC, so every server which knows key can validate it without knowing the negotiation.
+// connectionIDs never contain a ".", because they are URL encoded base64.
+func newConnectionToken(key []byte, connectionID string) string {
+	return connectionID + "." + base64.RawURLEncoding.EncodeToString(connectionTokenMAC(key, connectionID))
+}
+
+// parseConnectionToken validates token and returns the connectionID it was created for
+func parseConnectionToken(key []byte, token string) (connectionID string, ok bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return "", false
+	}
+	connectionID = token[:i]
+	if !hmac.Equal(mac, connectionTokenMAC(key, connectionID)) {
+		return "", false
+	}
+	return connectionID, true
+}
+
+func connectionTokenMAC(key []byte, connectionID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(connectionID))
+	return mac.Sum(nil)
+}
+
+// newConnectionTokenKey creates a random key. Unlike for connectionIDs, a failing random number generator
+// can not be ignored here, as everybody could sign connectionTokens with the resulting all-zero key.
+func newConnectionTokenKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("can not create connectionToken key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,114 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func negotiateTestServer(serverURL string, query string) negotiateResponse {
+	resp, err := http.Post(serverURL+"/negotiate"+query, "text/plain;charset=UTF-8", nil)
+	Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	nr := negotiateResponse{}
+	Expect(json.Unmarshal(body, &nr)).To(Succeed())
+	return nr
+}
+
+var _ = Describe("Connection tokens", func() {
+	Context("When a token is created", func() {
+		key := []byte("0123456789abcdef")
+		It("should be valid for the same key", func() {
+			token := newConnectionToken(key, "id")
+			connectionID, ok := parseConnectionToken(append([]byte(nil), key...), token)
+			Expect(ok).To(BeTrue())
+			Expect(connectionID).To(Equal("id"))
+		})
+		It("should be invalid for another key, another connectionID or without signature", func() {
+			token := newConnectionToken(key, "id")
+			_, ok := parseConnectionToken([]byte("fedcba9876543210"), token)
+			Expect(ok).To(BeFalse())
+			_, ok = parseConnectionToken(key, "other"+token[strings.IndexByte(token, '.'):])
+			Expect(ok).To(BeFalse())
+			_, ok = parseConnectionToken(key, "id")
+			Expect(ok).To(BeFalse())
+		})
+	})
+	Context("When the ConnectionTokenKey is too short", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), ConnectionTokenKey([]byte("short")))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When a client negotiates", func() {
+		var httpServer *httptest.Server
+		var server Server
+		var cancel context.CancelFunc
+		BeforeEach(func() {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			var err error
+			server, err = NewServer(ctx, SimpleHubFactory(&addHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.Handle("connectionID", func(ctx HubContext) string { return ctx.ConnectionID() })).To(Succeed())
+			httpServer = httptest.NewServer(server.Handler())
+		})
+		AfterEach(func() {
+			// End the served connections, the SSE client does not end its request on Stop
+			cancel()
+			httpServer.Close()
+		})
+		It("should send a connectionToken with negotiateVersion 1", func() {
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			Expect(nr.NegotiateVersion).To(Equal(1))
+			Expect(nr.ConnectionToken).NotTo(BeEmpty())
+			Expect(nr.ConnectionToken).NotTo(Equal(nr.ConnectionID))
+		})
+		It("should send no connectionToken without negotiateVersion", func() {
+			nr := negotiateTestServer(httpServer.URL, "")
+			Expect(nr.NegotiateVersion).To(Equal(0))
+			Expect(nr.ConnectionToken).To(BeEmpty())
+			Expect(nr.ConnectionID).NotTo(BeEmpty())
+		})
+		It("should not accept the connectionId for connect and send requests", func() {
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			req, err := http.NewRequest("GET", httpServer.URL+"?id="+url.QueryEscape(nr.ConnectionID), nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Accept", "text/event-stream")
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(404))
+			resp, err = http.Post(httpServer.URL+"?id="+url.QueryEscape(nr.ConnectionID), "text/plain", strings.NewReader("{}\u001e"))
+			Expect(err).NotTo(HaveOccurred())
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(404))
+		})
+		for _, transport := range []string{"WebSockets", "ServerSentEvents"} {
+			transport := transport
+			It("should show the connectionId, not the connectionToken, to the hub with "+transport, func(done Done) {
+				_ = HTTPTransports(transport)(server)
+				httpClient, err := NewHTTPClient(context.TODO(), httpServer.URL,
+					Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(httpClient.Start()).To(Succeed())
+				result := <-httpClient.Invoke("connectionID")
+				Expect(result.Error).NotTo(HaveOccurred())
+				Expect(result.Value).To(Equal(httpClient.(*client).conn.ConnectionID()))
+				Expect(result.Value).NotTo(ContainSubstring("."))
+				close(done)
+			}, 5.0)
+		}
+	})
+})
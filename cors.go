@@ -0,0 +1,73 @@
+package signalr
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// corsPolicy checks the Origin of http requests and sets the CORS headers.
+// Without origin patterns, the policy allows all origins and sets no headers.
+type corsPolicy struct {
+	originPatterns   []string
+	allowCredentials bool
+}
+
+// allowed checks if origin matches one of the origin patterns. Matching is case insensitive
+func (c *corsPolicy) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range c.originPatterns {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validate rejects the origin pattern "*" together with credentials. The policy sends the origin of the request
+// as allowed origin, so browsers would send credentials to the server from any site
+func (c *corsPolicy) validate() error {
+	if !c.allowCredentials {
+		return nil
+	}
+	for _, pattern := range c.originPatterns {
+		if pattern == "*" {
+			return errors.New("option AllowCredentials can not be used with the origin pattern \"*\"")
+		}
+	}
+	return nil
+}
+
+// apply checks the origin of request and sets the CORS headers. It returns true when the request
+// has been answered, either because the origin is not allowed or because it was a preflight request.
+// Requests without Origin header are not sent by browsers and are always allowed.
+func (c *corsPolicy) apply(writer http.ResponseWriter, request *http.Request) (answered bool) {
+	origin := request.Header.Get("Origin")
+	if len(c.originPatterns) == 0 || origin == "" {
+		return false
+	}
+	header := writer.Header()
+	header.Add("Vary", "Origin")
+	if !c.allowed(origin) {
+		writer.WriteHeader(403) // Forbidden
+		return true
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if c.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if request.Method == "OPTIONS" && request.Header.Get("Access-Control-Request-Method") != "" {
+		// Preflight
+		header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if requestHeaders := request.Header.Get("Access-Control-Request-Headers"); requestHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+		writer.WriteHeader(204) // No content
+		return true
+	}
+	return false
+}
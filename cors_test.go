@@ -0,0 +1,116 @@
+package signalr
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"strings"
+)
+
+func corsRequest(method string, url string, origin string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	Expect(err).NotTo(HaveOccurred())
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	_ = resp.Body.Close()
+	return resp
+}
+
+var _ = Describe("CORS", func() {
+	var httpServer *httptest.Server
+	newCORSServer := func(options ...func(Party) error) {
+		server, err := NewServer(context.TODO(), append([]func(Party) error{SimpleHubFactory(&addHub{})}, options...)...)
+		Expect(err).NotTo(HaveOccurred())
+		httpServer = httptest.NewServer(server.Handler())
+	}
+	AfterEach(func() {
+		if httpServer != nil {
+			httpServer.Close()
+			httpServer = nil
+		}
+	})
+
+	Context("When no origin patterns are set", func() {
+		It("should allow all origins and send no CORS headers", func() {
+			newCORSServer()
+			resp := corsRequest("POST", httpServer.URL+"/negotiate", "https://other.org")
+			Expect(resp.StatusCode).To(Equal(200))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		})
+	})
+	Context("When origin patterns are set", func() {
+		BeforeEach(func() {
+			newCORSServer(AllowOriginPatterns("https://*.example.com", "http://localhost:8080"), AllowCredentials())
+		})
+		It("should send CORS headers for allowed origins", func() {
+			for _, origin := range []string{"https://app.example.com", "HTTP://localhost:8080"} {
+				resp := corsRequest("POST", httpServer.URL+"/negotiate", origin)
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal(origin))
+				Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+			}
+		})
+		It("should reject other origins on negotiate, connect and send", func() {
+			for _, origin := range []string{"https://example.org", "https://app.example.com.evil.org", "http://localhost:8081"} {
+				Expect(corsRequest("POST", httpServer.URL+"/negotiate", origin).StatusCode).To(Equal(403))
+				Expect(corsRequest("GET", httpServer.URL+"?id=123", origin).StatusCode).To(Equal(403))
+				Expect(corsRequest("POST", httpServer.URL+"?id=123", origin).StatusCode).To(Equal(403))
+			}
+		})
+		It("should allow requests without origin", func() {
+			Expect(corsRequest("POST", httpServer.URL+"/negotiate", "").StatusCode).To(Equal(200))
+		})
+		It("should answer preflight requests", func() {
+			req, err := http.NewRequest("OPTIONS", httpServer.URL+"/negotiate", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "x-requested-with,x-signalr-user-agent")
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(204))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+			Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(ContainSubstring("POST"))
+			Expect(resp.Header.Get("Access-Control-Allow-Headers")).To(Equal("x-requested-with,x-signalr-user-agent"))
+		})
+		It("should reject WebSocket connections from other origins", func() {
+			wsURL := strings.Replace(httpServer.URL, "http", "ws", 1)
+			dial := func(origin string) (*websocket.Conn, *http.Response, error) {
+				return websocket.Dial(context.TODO(), wsURL, &websocket.DialOptions{
+					HTTPHeader: http.Header{"Origin": []string{origin}},
+				})
+			}
+			_, resp, err := dial("https://example.org")
+			Expect(err).To(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(403))
+			ws, _, err := dial("https://app.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			_ = ws.Close(websocket.StatusNormalClosure, "")
+		})
+	})
+	Context("When an origin pattern is invalid", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), AllowOriginPatterns("https://[.example.com"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When credentials are allowed for any origin", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), AllowOriginPatterns("*"), AllowCredentials())
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), AllowCredentials(),
+				AllowOriginPatterns("https://example.com", "*"))
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), AllowOriginPatterns("https://*.example.com"),
+				AllowCredentials())
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -3,7 +3,7 @@ module github.com/philippseith/signalr
 go 1.13
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/VividCortex/gohistogram v1.0.0 // indirect
 	github.com/go-kit/kit v0.9.0
 	github.com/go-logfmt/logfmt v0.4.0 // indirect
 	github.com/go-stack/stack v1.8.0 // indirect
@@ -16,8 +16,8 @@ require (
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/teivah/onecontext v0.0.0-20200513185103-40f981bfd775
 	github.com/tinylib/msgp v1.1.4
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
+	nhooyr.io/websocket v1.8.7
 )
@@ -0,0 +1,72 @@
+package signalr
+
+import (
+	"fmt"
+)
+
+// Handshake is the handshake request a client sent when its connection started
+type Handshake struct {
+	ConnectionID string
+	// Protocol is the name of the requested protocol, e.g. "json"
+	Protocol string
+	// Version is the requested version of the protocol
+	Version int
+	// RequestFeatures are the features of the http request which started the connection. For other connections,
+	// they are empty.
+	RequestFeatures *RequestFeatures
+}
+
+// statefulReconnectVersion is the first protocol version with Ack and Sequence messages
+const statefulReconnectVersion = 2
+
+// negotiateProtocol returns the protocol for a handshake request. accepted are the names of the protocols the
+// server accepts, nil accepts all registered protocols. The error is the reason why the request is rejected,
+// it is sent in the handshake response.
+func negotiateProtocol(conn Connection, request handshakeRequest, accepted []string) (HubProtocol, error) {
+	registered, ok := lookupProtocol(request.Protocol)
+	if !ok || (accepted != nil && !containsString(accepted, request.Protocol)) {
+		return nil, fmt.Errorf("the protocol '%v' is not supported", request.Protocol)
+	}
+	if request.Version < 1 || request.Version > registered.maxVersion {
+		return nil, fmt.Errorf("the server does not support version %v of the '%v' protocol", request.Version, request.Protocol)
+	}
+	if err := useTransferFormat(conn, registered.transferFormat); err != nil {
+		return nil, fmt.Errorf("the '%v' protocol can not be used on the current transport: %v", request.Protocol, err)
+	}
+	return registered.factory(), nil
+}
+
+// preferredProtocol returns the first of the protocols which is registered and can be used on conn,
+// and the version to request for it
+func preferredProtocol(conn Connection, protocols []string, stateful bool) (string, int, HubProtocol, error) {
+	for _, name := range protocols {
+		registered, ok := lookupProtocol(name)
+		if !ok || useTransferFormat(conn, registered.transferFormat) != nil {
+			continue
+		}
+		// Request version 1 if possible, as servers which only know version 1 reject higher versions
+		version := 1
+		if stateful && registered.maxVersion >= statefulReconnectVersion {
+			version = statefulReconnectVersion
+		}
+		return name, version, registered.factory(), nil
+	}
+	return "", 0, nil, fmt.Errorf("none of the protocols %v can be used on the connection", protocols)
+}
+
+// useTransferFormat sets the transfer format of conn, if conn distinguishes text and binary messages
+func useTransferFormat(conn Connection, format string) error {
+	if formatter, ok := conn.(transferFormatter); ok {
+		return formatter.setTransferFormat(format)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -4,20 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"golang.org/x/net/websocket"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"nhooyr.io/websocket"
+	"strings"
 )
 
 // NewHTTPClient creates a signalR Client using the websocket transport
 func NewHTTPClient(ctx context.Context, address string, options ...func(Party) error) (Client, error) {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%v/negotiate", address), nil)
+	// The client is created before the connection, because its options configure negotiation and transport
+	c, err := NewClient(ctx, nil, options...)
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	compression := c.(*client).webSocketCompression()
+	stateful := c.(*client).statefulReconnect()
+	negotiateURL, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	negotiateURL.Path = strings.TrimSuffix(negotiateURL.Path, "/") + "/negotiate"
+	q := negotiateURL.Query()
+	q.Set("negotiateVersion", "1")
+	if stateful.enabled {
+		q.Set("useStatefulReconnect", "true")
+	}
+	negotiateURL.RawQuery = q.Encode()
+	req, err := http.NewRequest("POST", negotiateURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -31,47 +50,83 @@ func NewHTTPClient(ctx context.Context, address string, options ...func(Party) e
 	if err != nil {
 		return nil, err
 	}
+	// Servers which do not support negotiateVersion 1 use the connectionId for the transport requests
+	connectionToken := nr.ConnectionID
+	if nr.NegotiateVersion >= 1 {
+		connectionToken = nr.ConnectionToken
+	}
 	reqURL, err := url.Parse(address)
 	if err != nil {
 		return nil, err
 	}
-	q := reqURL.Query()
-	q.Set("id", nr.ConnectionID)
+	q = reqURL.Query()
+	q.Set("id", connectionToken)
 	reqURL.RawQuery = q.Encode()
 	// Select the best connection
 	var conn Connection
 	if formats := nr.getTransferFormats("WebTransports"); formats != nil {
 		// TODO
 	} else if formats := nr.getTransferFormats("WebSockets"); formats != nil {
-		wsURL := reqURL
-		wsURL.Scheme = "ws"
-		ws, err := websocket.Dial(wsURL.String(), "", "http://localhost")
-		if err != nil {
+		if err = useProtocolsWithTransferFormats(c, formats); err != nil {
+			return nil, err
+		}
+		dial := func(ctx context.Context) (Connection, error) {
+			// Dial maps the http(s) scheme to ws(s)
+			ws, _, err := websocket.Dial(ctx, reqURL.String(), &websocket.DialOptions{
+				CompressionMode:      compression.mode(),
+				CompressionThreshold: compression.threshold,
+			})
+			if err != nil {
+				return nil, err
+			}
+			// The connection ends with the client context, which is a normal closure
+			return newWebSocketConnection(context.Background(), ctx, nr.ConnectionID, ws), nil
+		}
+		if nr.UseStatefulReconnect && stateful.enabled {
+			resumable := newResumableConnection(c.(*client).context(), nr.ConnectionID, stateful, dial)
+			transport, err := dial(resumable.Context())
+			if err != nil {
+				return nil, err
+			}
+			if _, err = resumable.attach(transport); err != nil {
+				return nil, err
+			}
+			conn = resumable
+		} else if conn, err = dial(ctx); err != nil {
 			return nil, err
 		}
-		conn = newWebSocketConnection(ctx, context.Background(), nr.ConnectionID, ws)
 	} else if formats := nr.getTransferFormats("ServerSentEvents"); formats != nil {
+		if err = useProtocolsWithTransferFormats(c, formats); err != nil {
+			return nil, err
+		}
 		req, err := http.NewRequest("GET", reqURL.String(), nil)
 		if err != nil {
 			return nil, err
 		}
 		req.Header.Set("Accept", "text/event-stream")
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := httpClient.Do(req)
 		if err != nil {
 			return nil, err
 		}
-		conn, err = newClientSSEConnection(ctx, address, nr.ConnectionID, resp.Body)
+		conn, err = newClientSSEConnection(ctx, address, nr.ConnectionID, connectionToken, resp.Body)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if conn != nil {
-		result, err := NewClient(ctx, conn, options...)
-		if err != nil {
-			return nil, err
-		}
-		return result, nil
+		c.(*client).conn = conn
+		return c, nil
 	}
 	return nil, nil
 }
+
+// useProtocolsWithTransferFormats restricts the protocols the client can request to those the transport
+// can transfer with the formats offered by the server
+func useProtocolsWithTransferFormats(c Client, formats []string) error {
+	protocols := protocolsWithTransferFormats(*c.protocols(), formats)
+	if len(protocols) == 0 {
+		return fmt.Errorf("the server offers none of the transfer formats of the protocols %v: %v", *c.protocols(), formats)
+	}
+	*c.protocols() = protocols
+	return nil
+}
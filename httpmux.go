@@ -6,27 +6,83 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"golang.org/x/net/websocket"
+	"github.com/go-kit/kit/metrics"
 	"net/http"
+	"nhooyr.io/websocket"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type httpMux struct {
-	mx            sync.Mutex
-	connectionMap map[string]Connection
-	server        Server
-	wsServer      websocket.Server
+	mx sync.Mutex
+	// connectionMap holds the negotiated connections by their connectionID
+	connectionMap map[string]*negotiatedConnection
+	// pending counts the negotiated but not connected connections per client address
+	pending map[string]int
+	server  Server
+}
+
+// negotiatedConnection is a connection from its negotiation to its end
+type negotiatedConnection struct {
+	connectionID string
+	// hubConnectionID is the id under which hubs know the connection.
+	// For negotiateVersion 1 it is the connectionID, for version 0 the connectionToken
+	hubConnectionID string
+	// client is the address of the negotiating client
+	client string
+	// expiry removes the connection when it is not connected in time. It is nil when there was no negotiation
+	expiry *time.Timer
+	// conn is nil until a transport connects. With stateful reconnect, it is the resumableConnection.
+	conn    Connection
+	claimed bool
+	// stateful is set when the client asked for stateful reconnect and the server allows it
+	stateful bool
+}
+
+// negotiationPolicy limits the connections which are negotiated but not connected
+type negotiationPolicy struct {
+	// timeout is the time after which a negotiated connection expires when it is not connected
+	timeout time.Duration
+	// maxPendingPerClient is the maximum number of pending negotiations per client address. 0 means no limit
+	maxPendingPerClient int
+	// gauge is set to the number of negotiated and connected connections
+	gauge metrics.Gauge
+}
+
+// mapHTTP registers the handlers for negotiation and connections of server on path
+func mapHTTP(mux *http.ServeMux, path string, server Server) {
+	httpMux := newHTTPMux(server)
+	mux.HandleFunc(fmt.Sprintf("%s/negotiate", path), httpMux.negotiate)
+	mux.Handle(path, httpMux)
+}
+
+// httpHandler serves negotiate, connect and send requests relative to the request path
+type httpHandler struct {
+	*httpMux
+}
+
+func (h *httpHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if path := request.URL.Path; path == "negotiate" || strings.HasSuffix(path, "/negotiate") {
+		h.negotiate(writer, request)
+	} else {
+		h.httpMux.ServeHTTP(writer, request)
+	}
 }
 
 func newHTTPMux(server Server) *httpMux {
 	return &httpMux{
-		connectionMap: make(map[string]Connection),
+		connectionMap: make(map[string]*negotiatedConnection),
+		pending:       make(map[string]int),
 		server:        server,
 	}
 }
 
 func (h *httpMux) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if h.server.cors().apply(writer, request) {
+		return
+	}
 	switch request.Method {
 	case "POST":
 		h.handlePost(writer, request)
@@ -37,27 +93,88 @@ func (h *httpMux) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
+// lookup returns the negotiatedConnection for the connectionToken in the request.
+// If there is none, it returns the http status code for the response.
+func (h *httpMux) lookup(request *http.Request) (nc *negotiatedConnection, status int) {
+	token := request.URL.Query().Get("id")
+	if token == "" {
+		return nil, 400 // Bad request
+	}
+	connectionID, ok := parseConnectionToken(h.server.connectionTokenKey(), token)
+	if !ok {
+		return nil, 404 // Not found
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if nc, ok = h.connectionMap[connectionID]; !ok {
+		return nil, 404 // Not found
+	}
+	return nc, 200
+}
+
+// claim marks the negotiated connection as connected by a transport. Only the first claim succeeds
+func (h *httpMux) claim(nc *negotiatedConnection) bool {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if nc.claimed {
+		return false
+	}
+	nc.claimed = true
+	if nc.expiry != nil {
+		nc.expiry.Stop()
+		decrement(h.pending, nc.client)
+	}
+	return true
+}
+
+// expire removes the negotiated connection if it was not connected
+func (h *httpMux) expire(nc *negotiatedConnection) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if nc.claimed {
+		return
+	}
+	// Claim it, so a transport which just looked it up can not connect
+	nc.claimed = true
+	decrement(h.pending, nc.client)
+	h.deleteConnection(nc)
+}
+
+// deleteConnection removes nc from the connectionMap. h.mx must be locked
+func (h *httpMux) deleteConnection(nc *negotiatedConnection) {
+	if h.connectionMap[nc.connectionID] == nc {
+		delete(h.connectionMap, nc.connectionID)
+	}
+	if gauge := h.server.negotiation().gauge; gauge != nil {
+		gauge.Set(float64(len(h.connectionMap)))
+	}
+}
+
+// addConnection adds nc to the connectionMap. h.mx must be locked
+func (h *httpMux) addConnection(nc *negotiatedConnection) {
+	h.connectionMap[nc.connectionID] = nc
+	if gauge := h.server.negotiation().gauge; gauge != nil {
+		gauge.Set(float64(len(h.connectionMap)))
+	}
+}
+
 func (h *httpMux) handlePost(writer http.ResponseWriter, request *http.Request) {
-	connectionID := request.URL.Query().Get("id")
-	if connectionID == "" {
-		writer.WriteHeader(400) // Bad request
+	nc, status := h.lookup(request)
+	if nc == nil {
+		writer.WriteHeader(status)
 		return
 	}
 	h.mx.Lock()
-	c, ok := h.connectionMap[connectionID]
+	c := nc.conn
 	h.mx.Unlock()
-	if ok {
-		// Connection is initiated
-		switch conn := c.(type) {
-		case *serverSSEConnection:
-			writer.WriteHeader(conn.consumeRequest(request))
-		// TODO case longPolling
-		default:
-			// ConnectionID for WebSocket or
-			writer.WriteHeader(409) // Conflict
-		}
-	} else {
-		writer.WriteHeader(404) // Not found
+	// Connection is initiated
+	switch conn := c.(type) {
+	case *serverSSEConnection:
+		writer.WriteHeader(conn.consumeRequest(request))
+	// TODO case longPolling
+	default:
+		// Not connected or connected with WebSocket
+		writer.WriteHeader(409) // Conflict
 	}
 }
 
@@ -69,127 +186,218 @@ func (h *httpMux) handleGet(writer http.ResponseWriter, request *http.Request) {
 			break
 		}
 	}
+	// Reconnecting clients are already admitted
+	if !h.resuming(request) {
+		if status, err := h.server.admission().check(NewRequestFeatures(request)); err != nil {
+			writer.WriteHeader(status)
+			return
+		}
+	}
 	if upgrade &&
 		strings.ToLower(request.Header.Get("Upgrade")) == "websocket" {
-		h.wsServer = websocket.Server{
-			// Use custom Handshake. Default with websocket.Handler is to reject nil origin.
-			// This not useful when testing using the typescript client outside the browser (e.g. in node.js)
-			// or any other client with origin not set.
-			Handshake: func(config *websocket.Config, req *http.Request) (err error) {
-				config.Origin, err = websocket.Origin(config, req)
-				return err
-			},
-			Handler: func(ws *websocket.Conn) { h.handleWebsocket(request.Context(), ws) },
-		}
-		h.wsServer.ServeHTTP(writer, request)
+		h.handleWebsocket(writer, request)
 	} else if strings.ToLower(request.Header.Get("Accept")) == "text/event-stream" {
-		connectionID := request.URL.Query().Get("id")
-		if connectionID == "" {
-			writer.WriteHeader(400) // Bad request
+		nc, status := h.lookup(request)
+		if nc == nil {
+			writer.WriteHeader(status)
 			return
 		}
-		h.mx.Lock()
-		c, ok := h.connectionMap[connectionID]
-		h.mx.Unlock()
-		if ok {
-			if c == nil {
-				// Connection is negotiated but not initiated
-				// Check for SSE
-				if strings.ToLower(request.Header.Get("Accept")) == "text/event-stream" {
-					// We compose http and send it over sse
-					writer.Header().Set("Content-Type", "text/event-stream")
-					writer.Header().Set("Connection", "keep-alive")
-					writer.Header().Set("Cache-Control", "no-cache")
-					writer.WriteHeader(200)
-					// End this Server Sent Event (yes, your response now is one and the client will wait for this initial event to end)
-					_, _ = fmt.Fprint(writer, ":\r\n\r\n")
-					writer.(http.Flusher).Flush()
-					if sseConn, err := newServerSSEConnection(h.server.context(), request.Context(), connectionID, writer); err != nil {
-						writer.WriteHeader(500) // Internal server error
-					} else {
-						h.serveConnection(sseConn)
-					}
-				}
-				//  TODO Long polling
-			} else {
-				// connectionID in use
-				writer.WriteHeader(409) // Conflict
-			}
+		if !h.claim(nc) {
+			// connectionID in use
+			writer.WriteHeader(409) // Conflict
+			return
+		}
+		// We compose http and send it over sse
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Connection", "keep-alive")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.WriteHeader(200)
+		// End this Server Sent Event (yes, your response now is one and the client will wait for this initial event to end)
+		_, _ = fmt.Fprint(writer, ":\r\n\r\n")
+		writer.(http.Flusher).Flush()
+		if sseConn, err := newServerSSEConnection(h.server.context(), request.Context(), nc.hubConnectionID, writer); err != nil {
+			writer.WriteHeader(500) // Internal server error
 		} else {
-			writer.WriteHeader(404) // Not found
+			sseConn.requestFeatures = NewRequestFeatures(request)
+			h.serveConnection(nc, sseConn)
 		}
+		//  TODO Long polling
 	} else {
 		writer.WriteHeader(400) // Bad request
 	}
 }
 
-func (h *httpMux) handleWebsocket(requestContext context.Context, ws *websocket.Conn) {
-	connectionID := ws.Request().URL.Query().Get("id")
-	if connectionID == "" {
+func (h *httpMux) handleWebsocket(writer http.ResponseWriter, request *http.Request) {
+	var nc *negotiatedConnection
+	if request.URL.Query().Get("id") == "" {
 		// Support websocket connection without negotiate
-		connectionID = newConnectionID()
+		connectionID := newConnectionID()
+		nc = &negotiatedConnection{connectionID: connectionID, hubConnectionID: connectionID}
 		h.mx.Lock()
-		h.connectionMap[connectionID] = nil
+		h.addConnection(nc)
 		h.mx.Unlock()
+	} else {
+		var status int
+		if nc, status = h.lookup(request); nc == nil {
+			writer.WriteHeader(status)
+			return
+		}
 	}
-	h.mx.Lock()
-	c, ok := h.connectionMap[connectionID]
-	h.mx.Unlock()
-	if ok {
-		if c == nil {
-			// Connection is negotiated but not initiated
-			h.serveConnection(newWebSocketConnection(h.server.context(), requestContext, connectionID, ws))
-		} else {
-			// Already initiated
-			_ = ws.WriteClose(409) // Bad request
-			// TODO Test client and server reaction (should we call c.cancel()?)
+	var resumable *resumableConnection
+	if !h.claim(nc) {
+		// Already initiated. A connection with stateful reconnect is resumed with the new transport
+		h.mx.Lock()
+		resumable, _ = nc.conn.(*resumableConnection)
+		h.mx.Unlock()
+		if resumable == nil {
+			writer.WriteHeader(409) // Conflict
+			return
 		}
-	} else {
-		_ = ws.WriteClose(404) // Not found
-		// TODO Test client and server reaction (should we call c.cancel()?)
 	}
+	ws, err := websocket.Accept(writer, request, &websocket.AcceptOptions{
+		// The origin is checked by the CORS policy of the server.
+		// Clients outside the browser (e.g. in node.js) do not send an origin.
+		InsecureSkipVerify:   true,
+		CompressionMode:      h.server.webSocketCompression().mode(),
+		CompressionThreshold: h.server.webSocketCompression().threshold,
+	})
+	if err != nil {
+		// Accept has sent the error response
+		if resumable == nil {
+			h.mx.Lock()
+			h.deleteConnection(nc)
+			h.mx.Unlock()
+		}
+		return
+	}
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	wsConn := newWebSocketConnection(h.server.context(), ctx, nc.hubConnectionID, ws)
+	wsConn.requestFeatures = NewRequestFeatures(request)
+	if interval := h.server.webSocket().pingInterval; interval > 0 {
+		go wsConn.keepAlive(ctx, interval)
+	}
+	switch {
+	case resumable != nil:
+		h.serveTransport(resumable, wsConn)
+	case nc.stateful:
+		resumable = newResumableConnection(h.server.context(), nc.hubConnectionID, h.server.statefulReconnect(), nil)
+		// Attach the first transport before the connection is served, so the admission and the handshake
+		// see its RequestFeatures
+		transportDone, err := resumable.attach(wsConn)
+		if err != nil {
+			h.mx.Lock()
+			h.deleteConnection(nc)
+			h.mx.Unlock()
+			return
+		}
+		h.mx.Lock()
+		nc.conn = resumable
+		h.mx.Unlock()
+		// The connection outlives its first transport
+		go func() {
+			h.serveConnection(nc, resumable)
+			resumable.close()
+		}()
+		<-transportDone
+	default:
+		h.serveConnection(nc, wsConn)
+	}
+}
+
+// serveTransport attaches the transport to the connection and returns when the transport is no longer used
+func (h *httpMux) serveTransport(resumable *resumableConnection, transport Connection) {
+	if done, err := resumable.attach(transport); err == nil {
+		<-done
+	}
+}
 
+// resuming returns if the request reconnects to a connection with stateful reconnect
+func (h *httpMux) resuming(request *http.Request) bool {
+	nc, _ := h.lookup(request)
+	if nc == nil {
+		return false
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	_, ok := nc.conn.(*resumableConnection)
+	return ok
 }
 
 func (h *httpMux) negotiate(w http.ResponseWriter, req *http.Request) {
+	if h.server.cors().apply(w, req) {
+		return
+	}
 	if req.Method != "POST" {
 		w.WriteHeader(400)
+	} else if status, err := h.server.admission().check(NewRequestFeatures(req)); err != nil {
+		w.WriteHeader(status)
 	} else {
 		connectionID := newConnectionID()
-		h.mx.Lock()
-		h.connectionMap[connectionID] = nil
-		h.mx.Unlock()
+		connectionToken := newConnectionToken(h.server.connectionTokenKey(), connectionID)
 		var availableTransports []availableTransport
+		formats := transferFormats(*h.server.protocols())
 		for _, transport := range h.server.availableTransports() {
 			switch transport {
 			case "ServerSentEvents":
-				availableTransports = append(availableTransports,
-					availableTransport{
-						Transport:       "ServerSentEvents",
-						TransferFormats: []string{"Text"},
-					})
+				// Server Sent Events can only transfer text
+				if containsString(formats, TransferFormatText) {
+					availableTransports = append(availableTransports,
+						availableTransport{
+							Transport:       "ServerSentEvents",
+							TransferFormats: []string{TransferFormatText},
+						})
+				}
 			case "WebSockets":
 				availableTransports = append(availableTransports,
 					availableTransport{
 						Transport:       "WebSockets",
-						TransferFormats: []string{"Text"},
-						// TODO TransferFormats: []string{"Text", "Binary"},
+						TransferFormats: formats,
 					})
 			}
 		}
 		response := negotiateResponse{
-			ConnectionID:        connectionID,
 			AvailableTransports: availableTransports,
 		}
+		nc := &negotiatedConnection{connectionID: connectionID, client: remoteHost(req.RemoteAddr)}
+		if h.server.statefulReconnect().enabled && req.URL.Query().Get("useStatefulReconnect") == "true" {
+			response.UseStatefulReconnect = true
+			nc.stateful = true
+		}
+		if version, err := strconv.Atoi(req.URL.Query().Get("negotiateVersion")); err == nil && version >= 1 {
+			// The connectionToken is only known by the client, hubs see the connectionID
+			response.NegotiateVersion = 1
+			response.ConnectionID = connectionID
+			response.ConnectionToken = connectionToken
+			nc.hubConnectionID = connectionID
+		} else {
+			// Version 0 clients use the connectionId for the transport requests
+			response.ConnectionID = connectionToken
+			nc.hubConnectionID = connectionToken
+		}
+		policy := h.server.negotiation()
+		h.mx.Lock()
+		if policy.maxPendingPerClient > 0 && h.pending[nc.client] >= policy.maxPendingPerClient {
+			h.mx.Unlock()
+			w.WriteHeader(429) // Too many requests
+			return
+		}
+		h.pending[nc.client]++
+		h.addConnection(nc)
+		nc.expiry = time.AfterFunc(policy.timeout, func() { h.expire(nc) })
+		h.mx.Unlock()
 		_ = json.NewEncoder(w).Encode(response) // Can't imagine an error when encoding
 	}
 }
 
-func (h *httpMux) serveConnection(c Connection) {
+func (h *httpMux) serveConnection(nc *negotiatedConnection, c Connection) {
 	h.mx.Lock()
-	h.connectionMap[c.ConnectionID()] = c
+	nc.conn = c
 	h.mx.Unlock()
 	h.server.ServeConnection(c)
+	h.mx.Lock()
+	h.deleteConnection(nc)
+	h.mx.Unlock()
 }
 
 func newConnectionID() string {
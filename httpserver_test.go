@@ -7,15 +7,15 @@ import (
 	"fmt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/mailru/easyjson/jwriter"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"golang.org/x/net/websocket"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"nhooyr.io/websocket"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -106,7 +106,7 @@ var _ = Describe("HTTP server", func() {
 				Expect(err).NotTo(HaveOccurred())
 				result := <-client.Invoke("Add2", 1)
 				Expect(result.Error).NotTo(HaveOccurred())
-				Expect(result.Value).To(Equal(float64(3)))
+				Expect(result.Value).To(Equal(json.Number("3")))
 				// Try second connection
 				client2, err := NewHTTPClient(context.TODO(),
 					fmt.Sprintf("http://127.0.0.1:%v/hub", port),
@@ -116,7 +116,7 @@ var _ = Describe("HTTP server", func() {
 				_ = client2.Start()
 				result = <-client2.Invoke("Add2", 2)
 				Expect(result.Error).NotTo(HaveOccurred())
-				Expect(result.Value).To(Equal(float64(4)))
+				Expect(result.Value).To(Equal(json.Number("4")))
 				// Huge message
 				hugo := strings.Repeat("#", 2500)
 				result = <-client.Invoke("Echo", hugo)
@@ -128,6 +128,48 @@ var _ = Describe("HTTP server", func() {
 			}, 100)
 		})
 	}
+	for _, transport := range []string{
+		"WebSockets",
+		"ServerSentEvents",
+	} {
+		Context("The Handler is mounted behind http.StripPrefix and a middleware", func() {
+			It(fmt.Sprintf("should serve %v connections relative to the request path", transport), func(done Done) {
+				logger := &nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}
+				server, err := NewServer(context.TODO(),
+					SimpleHubFactory(&addHub{}), HTTPTransports(transport),
+					Logger(logger, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.Handler()).To(BeIdenticalTo(server.Handler()))
+				var paths sync.Map
+				middleware := func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						paths.Store(r.URL.Path, true)
+						next.ServeHTTP(w, r)
+					})
+				}
+				router := http.NewServeMux()
+				router.Handle("/api/", http.StripPrefix("/api", middleware(server.Handler())))
+				port := freePort()
+				go func() {
+					_ = http.ListenAndServe(fmt.Sprintf("127.0.0.1:%v", port), router)
+				}()
+				waitForPort(port)
+				client, err := NewHTTPClient(context.TODO(),
+					fmt.Sprintf("http://127.0.0.1:%v/api/chat", port),
+					Logger(logger, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(client.Start()).To(Succeed())
+				result := <-client.Invoke("Add2", 1)
+				Expect(result.Error).NotTo(HaveOccurred())
+				Expect(result.Value).To(Equal(json.Number("3")))
+				_, negotiated := paths.Load("/chat/negotiate")
+				Expect(negotiated).To(BeTrue())
+				_, connected := paths.Load("/chat")
+				Expect(connected).To(BeTrue())
+				close(done)
+			}, 5.0)
+		})
+	}
 	Context("When no negotiation is send", func() {
 		It("should serve websocket requests", func(done Done) {
 			// Start server
@@ -193,26 +235,27 @@ func negotiateWebSocketTestServer(port int) map[string]interface{} {
 func handShakeAndCallWebSocketTestServer(port int, connectionID string) {
 	waitForPort(port)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	protocol := JSONHubProtocol{easyWriter: jwriter.Writer{}}
+	protocol := JSONHubProtocol{}
 	protocol.setDebugLogger(level.Debug(logger))
 	var urlParam string
 	if connectionID != "" {
 		urlParam = fmt.Sprintf("?id=%v", connectionID)
 	}
-	ws, err := websocket.Dial(fmt.Sprintf("ws://127.0.0.1:%v/hub%v", port, urlParam), "json", "http://127.0.0.1")
+	ws, _, err := websocket.Dial(context.TODO(), fmt.Sprintf("ws://127.0.0.1:%v/hub%v", port, urlParam), nil)
 	Expect(err).To(BeNil())
 	defer func() {
-		_ = ws.Close()
+		_ = ws.Close(websocket.StatusNormalClosure, "")
 	}()
 	wsConn := newWebSocketConnection(context.TODO(), context.TODO(), connectionID, ws)
-	cliConn := newHubConnection(wsConn, &protocol, 1<<15, log.NewLogfmtLogger(os.Stderr))
+	cliConn := newHubConnection(wsConn, &protocol, 1<<15,
+		outboundQueueConfig{capacity: 10, blockTimeout: time.Second}, nil, log.NewLogfmtLogger(os.Stderr))
 	_, _ = wsConn.Write(append([]byte(`{"protocol": "json","version": 1}`), 30))
 	_, _ = wsConn.Write(append([]byte(`{"type":1,"invocationId":"666","target":"add2","arguments":[1]}`), 30))
 	result := make(chan interface{})
 	go func() {
-		for {
-			if message, err := cliConn.Receive(); err == nil {
-				if completionMessage, ok := message.(completionMessage); ok {
+		for recv := range cliConn.Receive() {
+			if recv.err == nil {
+				if completionMessage, ok := recv.message.(completionMessage); ok {
 					result <- completionMessage.Result
 					return
 				}
@@ -221,7 +264,10 @@ func handShakeAndCallWebSocketTestServer(port int, connectionID string) {
 	}()
 	select {
 	case r := <-result:
-		Expect(r).To(Equal(3.0))
+		// The result is kept raw until the target type is known
+		var value float64
+		Expect(protocol.UnmarshalArgument(r, &value)).To(Succeed())
+		Expect(value).To(Equal(3.0))
 	case <-time.After(1000 * time.Millisecond):
 		Fail("timed out")
 	}
@@ -42,6 +42,11 @@ func (h *Hub) Logger() (info StructuredLogger, dbg StructuredLogger) {
 	return h.context.Logger()
 }
 
+// RequestFeatures returns a snapshot of the request which created the connection
+func (h *Hub) RequestFeatures() *RequestFeatures {
+	return h.context.RequestFeatures()
+}
+
 // OnConnected is called when the hub is connected
 func (h *Hub) OnConnected(string) {}
 
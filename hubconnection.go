@@ -3,6 +3,8 @@ package signalr
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/rotisserie/eris"
 	"sync"
 	"time"
@@ -12,20 +14,27 @@ import (
 // hubConnection uses a transport connection (of type Connection) and a HubProtocol to send and receive SignalR messages.
 type hubConnection interface {
 	ConnectionID() string
-	Receive() (interface{}, error)
+	Receive() <-chan receiveResult
 	SendInvocation(id string, target string, args []interface{}) error
 	SendStreamInvocation(id string, target string, args []interface{}, streamIds []string) error
+	SendPrepared(message *preparedMessage) error
 	StreamItem(id string, item interface{}) error
 	Completion(id string, result interface{}, error string) error
 	Close(error string, allowReconnect bool) error
 	Ping() error
 	LastWriteStamp() time.Time
 	Items() *sync.Map
+	RequestFeatures() *RequestFeatures
 	Context() context.Context
 	Abort()
+	AbortWithError(err error)
+	Err() error
 }
 
-func newHubConnection(connection Connection, protocol HubProtocol, maximumReceiveMessageSize uint, info StructuredLogger) hubConnection {
+// newHubConnection creates a hubConnection. received holds data which has already been read from the connection,
+// e.g. messages which were sent directly behind the handshake.
+func newHubConnection(connection Connection, protocol HubProtocol, maximumReceiveMessageSize uint,
+	outboundConfig outboundQueueConfig, received []byte, info StructuredLogger) hubConnection {
 	ctx, cancelFunc := context.WithCancel(connection.Context())
 	c := &defaultHubConnection{
 		ctx:                       ctx,
@@ -35,8 +44,22 @@ func newHubConnection(connection Connection, protocol HubProtocol, maximumReceiv
 		connection:                connection,
 		maximumReceiveMessageSize: maximumReceiveMessageSize,
 		items:                     &sync.Map{},
+		outbound:                  make(chan outboundItem, outboundConfig.capacity),
+		outboundConfig:            outboundConfig,
+		writeSem:                  make(chan struct{}, 1),
+		received:                  make(chan receiveResult),
 		info:                      info,
 	}
+	if limiter, ok := connection.(readLimiter); ok {
+		limiter.setReadLimit(int64(maximumReceiveMessageSize))
+	}
+	c.readBuf.Write(received)
+	go c.writeLoop()
+	if resumable, ok := connection.(*resumableConnection); ok && resumable.enabled() {
+		c.resumable = resumable
+		resumable.useProtocol(protocol)
+		go c.ackLoop()
+	}
 	return c
 }
 
@@ -49,6 +72,15 @@ type defaultHubConnection struct {
 	maximumReceiveMessageSize uint
 	items                     *sync.Map
 	lastWriteStamp            time.Time
+	abortErr                  error
+	outbound                  chan outboundItem
+	outboundConfig            outboundQueueConfig
+	dropOldestMx              sync.Mutex
+	writeSem                  chan struct{}
+	receiveOnce               sync.Once
+	received                  chan receiveResult
+	readBuf                   bytes.Buffer
+	resumable                 *resumableConnection
 	info                      StructuredLogger
 }
 
@@ -56,13 +88,54 @@ func (c *defaultHubConnection) Items() *sync.Map {
 	return c.items
 }
 
+// RequestFeatures returns the RequestFeatures of the connection if it implements ConnectionWithRequestFeatures
+func (c *defaultHubConnection) RequestFeatures() *RequestFeatures {
+	if conn, ok := c.connection.(ConnectionWithRequestFeatures); ok {
+		return conn.RequestFeatures()
+	}
+	return emptyRequestFeatures
+}
+
+// Close sends a close message. Messages which are queued before are sent before the close message.
+// If the connection is already aborted, the queued messages are dropped and only the close message is sent.
 func (c *defaultHubConnection) Close(errorText string, allowReconnect bool) error {
 	var closeMessage = closeMessage{
 		Type:           7,
 		Error:          errorText,
 		AllowReconnect: allowReconnect,
 	}
-	return c.protocol.WriteMessage(closeMessage, c.connection)
+	timer := time.NewTimer(c.outboundConfig.blockTimeout)
+	defer timer.Stop()
+	written := make(chan error, 1)
+	if c.ctx.Err() == nil {
+		select {
+		case c.outbound <- outboundItem{message: closeMessage, enqueued: time.Now(), written: written}:
+			select {
+			case err := <-written:
+				return err
+			case <-timer.C:
+				return errors.New("timeout waiting for queued messages to send close message")
+			case <-c.ctx.Done():
+				// The writeLoop has ended, maybe before writing the close message
+			}
+		case <-timer.C:
+			return errors.New("timeout waiting for the outbound queue to send close message")
+		case <-c.ctx.Done():
+		}
+	}
+	// The close message must not interleave with a message written by the writeLoop
+	select {
+	case c.writeSem <- struct{}{}:
+		defer func() { <-c.writeSem }()
+	case <-timer.C:
+		return errors.New("timeout waiting for pending write to send close message")
+	}
+	select {
+	case err := <-written:
+		return err
+	default:
+		return c.protocol.WriteMessage(closeMessage, c.connection)
+	}
 }
 
 func (c *defaultHubConnection) ConnectionID() string {
@@ -77,62 +150,129 @@ func (c *defaultHubConnection) Abort() {
 	c.cancelFunc()
 }
 
+// AbortWithError aborts the connection. err is reported by Err() and sent to the other Party with the close message.
+// Only the first error is kept.
+func (c *defaultHubConnection) AbortWithError(err error) {
+	c.mx.Lock()
+	if c.abortErr == nil {
+		c.abortErr = err
+	}
+	c.mx.Unlock()
+	c.cancelFunc()
+}
+
+// Err returns nil as long as the connection is not aborted.
+// After abort, it returns the error passed to AbortWithError or the error of the connection context.
+func (c *defaultHubConnection) Err() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.abortErr != nil {
+		return c.abortErr
+	}
+	if c.ctx.Err() != nil {
+		return eris.Wrap(c.ctx.Err(), "hubConnection canceled")
+	}
+	return nil
+}
+
 type receiveResult struct {
 	message interface{}
 	err     error
 }
 
-func (c *defaultHubConnection) Receive() (interface{}, error) {
-	if c.ctx.Err() != nil {
-		return nil, eris.Wrap(c.ctx.Err(), "hubConnection canceled")
-	}
-	recvResCh := make(chan receiveResult, 1)
-	go func(chan receiveResult) {
-		var buf bytes.Buffer
-		var data = make([]byte, c.maximumReceiveMessageSize)
-		var nn int
+// readBufferSize is the size of the chunks in which data is read from the connection.
+const readBufferSize = 1 << 12
+
+// readBufferPool holds the chunks for reading from connections. A chunk is only held while reading.
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, readBufferSize)
+		return &b
+	},
+}
+
+// Receive returns the channel of received messages. The first call starts the reader of the connection,
+// which runs until an error occurs or the connection is canceled. A read error aborts the connection, Err() returns it.
+func (c *defaultHubConnection) Receive() <-chan receiveResult {
+	c.receiveOnce.Do(func() {
+		go c.readLoop()
+	})
+	return c.received
+}
+
+func (c *defaultHubConnection) readLoop() {
+	buf := &c.readBuf
+	for {
+		// Dispatch all complete messages in buf
 		for {
-			if message, complete, parseErr := c.protocol.ReadMessage(&buf); !complete {
-				// Partial message, need more data
-				// ReadMessage read data out of the buf, so its gone there: refill
-				buf.Write(data[:nn])
-				readResCh := make(chan receiveResult, 1)
-				go func(nn int, readResCh chan receiveResult) {
-					var readErr error
-					n, readErr := c.connection.Read(data[nn:])
-					if readErr == nil {
-						buf.Write(data[nn : nn+n])
-						nn = nn + n
-					}
-					readResCh <- receiveResult{nn, readErr}
-					close(readResCh)
-				}(nn, readResCh)
-				select {
-				case readRes := <-readResCh:
-					if readRes.err != nil {
-						c.Abort()
-						recvResCh <- readRes
-						close(recvResCh)
-						return
-					}
-					nn = readRes.message.(int)
-				case <-c.ctx.Done():
-					recvResCh <- receiveResult{err: eris.Wrap(c.ctx.Err(), "hubConnection canceled")}
-					close(recvResCh)
-					return
+			buffered := buf.Len()
+			message, complete, err := c.protocol.ReadMessage(buf)
+			if !complete {
+				break
+			}
+			// The size of a message is the size of its frame, so it does not depend on the framing of the protocol
+			if size := uint(buffered - buf.Len()); size > c.maximumReceiveMessageSize {
+				c.abortMessageTooLarge(size)
+				return
+			}
+			if c.resumable != nil && err == nil {
+				var forward bool
+				if forward, err = c.resumable.receive(message); !forward && err == nil {
+					continue
 				}
-			} else {
-				recvResCh <- receiveResult{message, parseErr}
-				close(recvResCh)
+			}
+			if !c.sendReceiveResult(receiveResult{message: message, err: err}) || err != nil {
 				return
 			}
 		}
-	}(recvResCh)
+		if buf.Len() == 0 {
+			// Keep the capacity, but drop the consumed bytes
+			buf.Reset()
+		} else if uint(buf.Len()) > c.maximumReceiveMessageSize {
+			// The incomplete message in buf is already too large
+			c.abortMessageTooLarge(uint(buf.Len()))
+			return
+		}
+		data := readBufferPool.Get().(*[]byte)
+		n, err := c.connection.Read(*data)
+		buf.Write((*data)[:n])
+		readBufferPool.Put(data)
+		if c.resumable != nil && errors.Is(err, errTransportLost) {
+			// A partially received message is lost with the transport. The other Party resends it
+			buf.Reset()
+			c.resumable.buffer.disconnected()
+			continue
+		}
+		if err != nil {
+			c.AbortWithError(err)
+			return
+		}
+	}
+}
+
+func (c *defaultHubConnection) abortMessageTooLarge(size uint) {
+	err := &messageTooLargeError{size: size, maximumSize: c.maximumReceiveMessageSize}
+	_ = c.info.Log(evt, msgRecv, "error", err, react, "close connection")
+	c.AbortWithError(err)
+}
+
+// messageTooLargeError is the reason for closing a connection which received a message
+// larger than the MaximumReceiveMessageSize
+type messageTooLargeError struct {
+	size        uint
+	maximumSize uint
+}
+
+func (e *messageTooLargeError) Error() string {
+	return fmt.Sprintf("message size of at least %v bytes exceeds the maximum receive message size of %v bytes", e.size, e.maximumSize)
+}
+
+func (c *defaultHubConnection) sendReceiveResult(result receiveResult) bool {
 	select {
-	case recvRes := <-recvResCh:
-		return recvRes.message, recvRes.err
+	case c.received <- result:
+		return true
 	case <-c.ctx.Done():
-		return nil, eris.Wrap(c.ctx.Err(), "hubConnection canceled")
+		return false
 	}
 }
 
@@ -157,6 +297,11 @@ func (c *defaultHubConnection) SendStreamInvocation(id string, target string, ar
 	return c.writeMessage(invocationMessage)
 }
 
+// SendPrepared sends a message which is shared with other connections and encoded only once
+func (c *defaultHubConnection) SendPrepared(message *preparedMessage) error {
+	return c.writeMessage(message)
+}
+
 func (c *defaultHubConnection) StreamItem(id string, item interface{}) error {
 	var streamItemMessage = streamItemMessage{
 		Type:         2,
@@ -184,31 +329,148 @@ func (c *defaultHubConnection) Ping() error {
 }
 
 func (c *defaultHubConnection) LastWriteStamp() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
 	return c.lastWriteStamp
 }
 
 func (c *defaultHubConnection) writeMessage(message interface{}) error {
-	c.mx.Lock()
-	c.lastWriteStamp = time.Now()
-	c.mx.Unlock()
-	err := func() error {
-		if c.ctx.Err() != nil {
-			return eris.Wrap(c.ctx.Err(), "hubConnection canceled")
+	err := c.enqueue(outboundItem{message: message, enqueued: time.Now()})
+	if err != nil {
+		_ = c.info.Log(evt, msgSend, "message", fmtMsg(message), "error", err)
+	}
+	return err
+}
+
+// enqueue puts the item into the outbound queue. If the queue is full, the OverflowPolicy is applied
+func (c *defaultHubConnection) enqueue(item outboundItem) error {
+	if c.ctx.Err() != nil {
+		return eris.Wrap(c.ctx.Err(), "hubConnection canceled")
+	}
+	select {
+	case c.outbound <- item:
+		return nil
+	default:
+	}
+	switch c.outboundConfig.policy {
+	case OverflowDropNewest:
+		c.countDrop(dropReasonNewest)
+		return &outboundQueueFullError{dropReasonNewest}
+	case OverflowDropOldest:
+		c.dropOldestMx.Lock()
+		defer c.dropOldestMx.Unlock()
+		for {
+			select {
+			case c.outbound <- item:
+				return nil
+			default:
+				select {
+				case <-c.outbound:
+					c.countDrop(dropReasonOldest)
+				default:
+				}
+			}
 		}
-		e := make(chan error, 1)
-		go func() { e <- c.protocol.WriteMessage(message, c.connection) }()
+	case OverflowDisconnect:
+		c.countDrop(dropReasonDisconnect)
+		err := &outboundQueueFullError{dropReasonDisconnect}
+		c.AbortWithError(err)
+		return err
+	default:
+		timer := time.NewTimer(c.outboundConfig.blockTimeout)
+		defer timer.Stop()
 		select {
+		case c.outbound <- item:
+			return nil
+		case <-timer.C:
+			c.countDrop(dropReasonTimeout)
+			return &outboundQueueFullError{dropReasonTimeout}
 		case <-c.ctx.Done():
 			return eris.Wrap(c.ctx.Err(), "hubConnection canceled")
-		case err := <-e:
+		}
+	}
+}
+
+// writeLoop is the only writer of queued messages to the connection. After abort, it writes no more messages
+func (c *defaultHubConnection) writeLoop() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case item := <-c.outbound:
+			if item.written == nil && c.outboundConfig.ttl > 0 && time.Since(item.enqueued) > c.outboundConfig.ttl {
+				c.countDrop(dropReasonTTL)
+				continue
+			}
+			c.writeSem <- struct{}{}
+			if c.ctx.Err() != nil {
+				<-c.writeSem
+				return
+			}
+			err := c.write(item.message)
+			if item.written != nil {
+				item.written <- err
+			}
+			<-c.writeSem
+			if c.resumable != nil && errors.Is(err, errTransportLost) {
+				// Sequenced messages are resent with the next transport, others are dropped
+				continue
+			}
 			if err != nil {
-				c.Abort()
+				_ = c.info.Log(evt, msgSend, "message", fmtMsg(item.message), "error", err, react, "close connection")
+				c.AbortWithError(err)
+				return
 			}
+			c.mx.Lock()
+			c.lastWriteStamp = time.Now()
+			c.mx.Unlock()
+		}
+	}
+}
+
+func (c *defaultHubConnection) write(message interface{}) error {
+	if prepared, ok := message.(*preparedMessage); ok {
+		data, err := prepared.encode(c.protocol)
+		if err != nil {
 			return err
 		}
-	}()
-	if err != nil {
-		_ = c.info.Log(evt, msgSend, "message", fmtMsg(message), "error", err)
+		if c.resumable != nil {
+			return c.resumable.writeSequenced(c.ctx, data)
+		}
+		_, err = c.connection.Write(data)
+		return err
+	}
+	if c.resumable != nil && isSequenced(message) {
+		var buf bytes.Buffer
+		if err := c.protocol.WriteMessage(message, &buf); err != nil {
+			return err
+		}
+		return c.resumable.writeSequenced(c.ctx, buf.Bytes())
+	}
+	return c.protocol.WriteMessage(message, c.connection)
+}
+
+// ackInterval is the interval in which received sequenced messages are acknowledged with stateful reconnect
+const ackInterval = time.Second
+
+// ackLoop acknowledges the received sequenced messages of a connection with stateful reconnect
+func (c *defaultHubConnection) ackLoop() {
+	ticker := time.NewTicker(ackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if id, ok := c.resumable.buffer.pendingAck(); ok {
+				_ = c.writeMessage(ackMessage{Type: 8, SequenceID: id})
+			}
+		}
+	}
+}
+
+func (c *defaultHubConnection) countDrop(reason string) {
+	if c.outboundConfig.dropCounter != nil {
+		c.outboundConfig.dropCounter.With("reason", reason).Add(1)
 	}
-	return err
 }
@@ -0,0 +1,307 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipeWriterConnection writes into an io.Pipe, so the other end sees exactly the bytes on the wire
+type pipeWriterConnection struct {
+	baseConnection
+	writer io.Writer
+}
+
+func (p *pipeWriterConnection) Read([]byte) (n int, err error) {
+	<-p.ctx.Done()
+	return 0, p.ctx.Err()
+}
+
+func (p *pipeWriterConnection) Write(b []byte) (n int, err error) {
+	return p.writer.Write(b)
+}
+
+// messagePackProtocol frames messages like the SignalR MessagePack protocol with a VarInt length prefix.
+// The messages are encoded with the generated msgp code.
+type messagePackProtocol struct{}
+
+func (m *messagePackProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, error) {
+	size, n := binary.Uvarint(buf.Bytes())
+	if n < 0 {
+		return nil, true, errors.New("invalid length prefix")
+	}
+	if n == 0 || uint64(buf.Len()-n) < size {
+		return nil, false, nil
+	}
+	buf.Next(n)
+	data := buf.Next(int(size))
+	var message hubMessage
+	if _, err := message.UnmarshalMsg(data); err != nil {
+		return nil, true, err
+	}
+	switch message.Type {
+	case 1, 4:
+		var invocation invocationMessage
+		_, err := invocation.UnmarshalMsg(data)
+		return invocation, true, err
+	default:
+		return message, true, nil
+	}
+}
+
+func (m *messagePackProtocol) WriteMessage(message interface{}, writer io.Writer) error {
+	if invocation, ok := message.(invocationMessage); ok {
+		message = &invocation
+	}
+	marshaler, ok := message.(msgp.Marshaler)
+	if !ok {
+		return fmt.Errorf("unexpected message %#v", message)
+	}
+	data, err := marshaler.MarshalMsg(nil)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(data)))
+	_, err = writer.Write(append(prefix[:n], data...))
+	return err
+}
+
+func (m *messagePackProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
+	return errors.New("not supported")
+}
+
+var _ = Describe("Concurrent writes", func() {
+	Context("When many goroutines send invocations and stream items over one connection", func() {
+		It("should write each message as a complete frame, ordered per sender", func(done Done) {
+			const senders = 50
+			const messagesPerSender = 100
+			reader, writer := io.Pipe()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			protocol := &JSONHubProtocol{}
+			protocol.setDebugLogger(log.NewNopLogger())
+			hubConn := newHubConnection(&pipeWriterConnection{
+				baseConnection: baseConnection{ctx: ctx, connectionID: "concurrent"},
+				writer:         writer,
+			}, protocol, 1<<15, outboundQueueConfig{capacity: 16, blockTimeout: 5 * time.Second}, nil, log.NewNopLogger())
+			// Read frames from the wire
+			frames := make(chan []byte, senders*messagesPerSender)
+			go func() {
+				var buf bytes.Buffer
+				data := make([]byte, 1<<10)
+				for {
+					n, err := reader.Read(data)
+					if err != nil {
+						close(frames)
+						return
+					}
+					buf.Write(data[:n])
+					for {
+						i := bytes.IndexByte(buf.Bytes(), 30)
+						if i < 0 {
+							break
+						}
+						frame := make([]byte, i)
+						copy(frame, buf.Next(i+1))
+						frames <- frame
+					}
+				}
+			}()
+			var wg sync.WaitGroup
+			wg.Add(senders)
+			for s := 0; s < senders; s++ {
+				go func(s int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					id := fmt.Sprint(s)
+					for m := 0; m < messagesPerSender; m++ {
+						if m%2 == 0 {
+							Expect(hubConn.SendInvocation("", id, []interface{}{m})).To(Succeed())
+						} else {
+							Expect(hubConn.StreamItem(id, m)).To(Succeed())
+						}
+					}
+				}(s)
+			}
+			wg.Wait()
+			last := make(map[string]int)
+			for i := 0; i < senders*messagesPerSender; i++ {
+				frame := <-frames
+				var message struct {
+					Type         int           `json:"type"`
+					Target       string        `json:"target"`
+					InvocationID string        `json:"invocationId"`
+					Arguments    []interface{} `json:"arguments"`
+					Item         interface{}   `json:"item"`
+				}
+				Expect(json.Unmarshal(frame, &message)).To(Succeed(), string(frame))
+				var sender string
+				var seq int
+				switch message.Type {
+				case 1:
+					sender, seq = message.Target, int(message.Arguments[0].(float64))
+				case 2:
+					sender, seq = message.InvocationID, int(message.Item.(float64))
+				default:
+					Fail(fmt.Sprintf("unexpected frame %v", string(frame)))
+				}
+				if prev, ok := last[sender]; ok {
+					Expect(seq).To(Equal(prev+1), "frames of sender %v out of order", sender)
+				} else {
+					Expect(seq).To(Equal(0))
+				}
+				last[sender] = seq
+			}
+			Expect(last).To(HaveLen(senders))
+			close(done)
+		}, 10.0)
+	})
+	Context("When one JSONHubProtocol writes messages from many goroutines", func() {
+		It("should encode every message completely", func(done Done) {
+			protocol := &JSONHubProtocol{}
+			protocol.setDebugLogger(log.NewNopLogger())
+			var wg sync.WaitGroup
+			for g := 0; g < 20; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for i := 0; i < 100; i++ {
+						var buf bytes.Buffer
+						Expect(protocol.WriteMessage(completionMessage{Type: 3, InvocationID: fmt.Sprint(g), Result: i}, &buf)).To(Succeed())
+						Expect(buf.String()).To(Equal(fmt.Sprintf("{\"type\":3,\"invocationId\":\"%v\",\"result\":%v}\u001e", g, i)))
+					}
+				}(g)
+			}
+			wg.Wait()
+			close(done)
+		}, 5.0)
+	})
+})
+
+func connectFraming(options ...func(Party) error) *testingConnection {
+	server, err := NewServer(context.TODO(), append([]func(Party) error{SimpleHubFactory(&addHub{}),
+		Logger(log.NewLogfmtLogger(os.Stderr), false)}, options...)...)
+	if err != nil {
+		Fail(err.Error())
+		return nil
+	}
+	conn := newTestingConnection()
+	go receiveLoop(conn)()
+	conn.SetConnected(true)
+	go server.ServeConnection(conn)
+	return conn
+}
+
+func receiveCompletion(conn *testingConnection) completionMessage {
+	for {
+		if completion, ok := (<-conn.ReceiveChan()).(completionMessage); ok {
+			return completion
+		}
+	}
+}
+
+var _ = Describe("Framing", func() {
+	Context("When the handshake and an invocation arrive in one read", func() {
+		It("should process the invocation", func(done Done) {
+			conn := connectFraming()
+			conn.ClientSend("{\"protocol\": \"json\",\"version\": 1}\u001e{\"type\":1,\"invocationId\":\"1\",\"target\":\"add2\",\"arguments\":[1]}")
+			Expect(receiveCompletion(conn).Result).To(Equal(3.0))
+			close(done)
+		}, 2.0)
+	})
+	Context("When several messages arrive in one read", func() {
+		It("should process all of them", func(done Done) {
+			conn := connectFraming()
+			conn.ClientSend(`{"protocol": "json","version": 1}`)
+			conn.ClientSend("{\"type\":1,\"invocationId\":\"1\",\"target\":\"add2\",\"arguments\":[1]}\u001e" +
+				"{\"type\":1,\"invocationId\":\"2\",\"target\":\"add2\",\"arguments\":[2]}\u001e" +
+				"{\"type\":1,\"invocationId\":\"3\",\"target\":\"add2\",\"arguments\":[3]}")
+			results := make(map[string]interface{})
+			for len(results) < 3 {
+				completion := receiveCompletion(conn)
+				results[completion.InvocationID] = completion.Result
+			}
+			Expect(results).To(Equal(map[string]interface{}{"1": 3.0, "2": 4.0, "3": 5.0}))
+			close(done)
+		}, 2.0)
+	})
+	Context("When a message is larger than the read buffer", func() {
+		It("should receive it completely", func(done Done) {
+			conn := connectFraming()
+			conn.ClientSend(`{"protocol": "json","version": 1}`)
+			huge := strings.Repeat("#", 5*readBufferSize)
+			conn.ClientSend(fmt.Sprintf(`{"type":1,"invocationId":"1","target":"echo","arguments":["%v"]}`, huge))
+			Expect(receiveCompletion(conn).Result).To(Equal(huge))
+			close(done)
+		}, 2.0)
+	})
+	Context("When a message is larger than the MaximumReceiveMessageSize", func() {
+		It("should close the connection with an error", func(done Done) {
+			conn := connectFraming(MaximumReceiveMessageSize(1 << 10))
+			conn.ClientSend(`{"protocol": "json","version": 1}`)
+			conn.ClientSend(fmt.Sprintf(`{"type":1,"invocationId":"1","target":"echo","arguments":["%v"]}`, strings.Repeat("#", 1<<12)))
+			for {
+				if message, ok := (<-conn.ReceiveChan()).(closeMessage); ok {
+					Expect(message.Error).To(ContainSubstring("exceeds the maximum receive message size of 1024 bytes"))
+					break
+				}
+			}
+			close(done)
+		}, 2.0)
+		It("should close the connection when a complete message in the buffer is too large", func(done Done) {
+			conn := connectFraming(MaximumReceiveMessageSize(100))
+			conn.ClientSend(`{"protocol": "json","version": 1}`)
+			conn.ClientSend(fmt.Sprintf(`{"type":1,"invocationId":"1","target":"echo","arguments":["%v"]}`, strings.Repeat("#", 200)))
+			for {
+				if message, ok := (<-conn.ReceiveChan()).(closeMessage); ok {
+					Expect(message.Error).To(ContainSubstring("exceeds the maximum receive message size of 100 bytes"))
+					break
+				}
+			}
+			close(done)
+		}, 2.0)
+	})
+	Context("When a MessagePack message is larger than the MaximumReceiveMessageSize", func() {
+		It("should count its length prefix", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			protocol := &messagePackProtocol{}
+			var frame bytes.Buffer
+			Expect(protocol.WriteMessage(invocationMessage{Type: 1, Target: "echo",
+				Arguments: []interface{}{strings.Repeat("#", 200)}}, &frame)).To(Succeed())
+			// The length prefix has 2 bytes
+			Expect(frame.Bytes()[0] & 0x80).NotTo(BeZero())
+			connect := func(maximumReceiveMessageSize int) hubConnection {
+				return newHubConnection(&pipeWriterConnection{
+					baseConnection: baseConnection{ctx: ctx, connectionID: "msgpack"},
+					writer:         ioutil.Discard,
+				}, protocol, uint(maximumReceiveMessageSize), outboundQueueConfig{capacity: 1, blockTimeout: time.Second},
+					frame.Bytes(), log.NewNopLogger())
+			}
+			accepted := connect(frame.Len())
+			result := <-accepted.Receive()
+			Expect(result.err).NotTo(HaveOccurred())
+			Expect(result.message.(invocationMessage).Target).To(Equal("echo"))
+			rejected := connect(frame.Len() - 1)
+			rejected.Receive()
+			Eventually(rejected.Err).Should(BeAssignableToTypeOf(&messageTooLargeError{}))
+			Expect(rejected.Err().(*messageTooLargeError).size).To(Equal(uint(frame.Len())))
+			close(done)
+		}, 2.0)
+	})
+})
@@ -12,6 +12,7 @@ import (
 // ConnectionID() gets the ID of the current connection
 // Abort() aborts the current connection
 // Logger() returns the logger used in this server
+// RequestFeatures() returns a snapshot of the request which created the current connection
 type HubContext interface {
 	Clients() HubClients
 	Groups() GroupManager
@@ -19,6 +20,7 @@ type HubContext interface {
 	ConnectionID() string
 	Abort()
 	Logger() (info StructuredLogger, dbg StructuredLogger)
+	RequestFeatures() *RequestFeatures
 }
 
 type connectionHubContext struct {
@@ -53,3 +55,7 @@ func (c *connectionHubContext) Abort() {
 func (c *connectionHubContext) Logger() (info StructuredLogger, dbg StructuredLogger) {
 	return c.info, c.dbg
 }
+
+func (c *connectionHubContext) RequestFeatures() *RequestFeatures {
+	return c.connection.RequestFeatures()
+}
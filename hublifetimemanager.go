@@ -45,8 +45,9 @@ func (d *defaultHubLifetimeManager) OnDisconnected(conn hubConnection) {
 }
 
 func (d *defaultHubLifetimeManager) InvokeAll(target string, args []interface{}) {
+	message := newBroadcastMessage(target, args)
 	d.clients.Range(func(key, value interface{}) bool {
-		_ = value.(hubConnection).SendInvocation("", target, args)
+		_ = value.(hubConnection).SendPrepared(message)
 		return true
 	})
 }
@@ -59,8 +60,9 @@ func (d *defaultHubLifetimeManager) InvokeClient(connectionID string, target str
 
 func (d *defaultHubLifetimeManager) InvokeGroup(groupName string, target string, args []interface{}) {
 	if groups, ok := d.groups.Load(groupName); ok {
+		message := newBroadcastMessage(target, args)
 		for _, v := range groups.(map[string]hubConnection) {
-			_ = v.SendInvocation("", target, args)
+			_ = v.SendPrepared(message)
 		}
 	}
 }
@@ -77,3 +79,12 @@ func (d *defaultHubLifetimeManager) RemoveFromGroup(groupName string, connection
 		delete(groups.(map[string]hubConnection), connectionID)
 	}
 }
+
+// newBroadcastMessage builds an invocation which is encoded once and shared by all receiving connections
+func newBroadcastMessage(target string, args []interface{}) *preparedMessage {
+	return newPreparedMessage(invocationMessage{
+		Type:      1,
+		Target:    target,
+		Arguments: args,
+	})
+}
@@ -0,0 +1,101 @@
+package signalr
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discardConnection counts written frames and throws them away
+type discardConnection struct {
+	baseConnection
+	written *sync.WaitGroup
+}
+
+func (d *discardConnection) Read([]byte) (n int, err error) {
+	<-d.ctx.Done()
+	return 0, d.ctx.Err()
+}
+
+func (d *discardConnection) Write(p []byte) (n int, err error) {
+	d.written.Done()
+	return len(p), nil
+}
+
+func newBroadcastTestLifetimeManager(ctx context.Context, count int, written *sync.WaitGroup) (*defaultHubLifetimeManager, []hubConnection) {
+	lifetimeManager := newLifeTimeManager(log.NewNopLogger())
+	conns := make([]hubConnection, count)
+	for i := 0; i < count; i++ {
+		protocol := &JSONHubProtocol{}
+		protocol.setDebugLogger(log.NewNopLogger())
+		conns[i] = newHubConnection(&discardConnection{
+			baseConnection: baseConnection{ctx: ctx, connectionID: fmt.Sprint(i)},
+			written:        written,
+		}, protocol, 1<<15, outboundQueueConfig{capacity: 10, blockTimeout: time.Second}, nil, log.NewNopLogger())
+		lifetimeManager.OnConnected(conns[i])
+	}
+	return &lifetimeManager, conns
+}
+
+var broadcastTestArgs = []interface{}{"The quick brown fox jumps over the lazy dog", 42, map[string]float64{"x": 1.5, "y": 2.5}}
+
+var _ = Describe("HubLifetimeManager", func() {
+	Context("When an invocation is sent to all clients", func() {
+		It("should encode the invocation once and send the same bytes to all clients", func(done Done) {
+			var written sync.WaitGroup
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			lifetimeManager, _ := newBroadcastTestLifetimeManager(ctx, 10, &written)
+			written.Add(10)
+			message := newBroadcastMessage("target", broadcastTestArgs)
+			lifetimeManager.clients.Range(func(key, value interface{}) bool {
+				Expect(value.(hubConnection).SendPrepared(message)).To(Succeed())
+				return true
+			})
+			written.Wait()
+			Expect(message.encoded).To(HaveLen(1))
+			for _, e := range message.encoded {
+				Expect(e.err).NotTo(HaveOccurred())
+				Expect(string(e.data)).To(HavePrefix(`{"type":1,"target":"target"`))
+			}
+			close(done)
+		})
+	})
+})
+
+func BenchmarkBroadcastEncodePerConnection(b *testing.B) {
+	benchmarkBroadcast(b, func(lifetimeManager *defaultHubLifetimeManager, conns []hubConnection) {
+		for _, conn := range conns {
+			_ = conn.SendInvocation("", "target", broadcastTestArgs)
+		}
+	})
+}
+
+func BenchmarkBroadcastEncodeOnce(b *testing.B) {
+	benchmarkBroadcast(b, func(lifetimeManager *defaultHubLifetimeManager, conns []hubConnection) {
+		lifetimeManager.InvokeAll("target", broadcastTestArgs)
+	})
+}
+
+func benchmarkBroadcast(b *testing.B, broadcast func(lifetimeManager *defaultHubLifetimeManager, conns []hubConnection)) {
+	for _, count := range []int{100, 10000} {
+		b.Run(fmt.Sprintf("%v clients", count), func(b *testing.B) {
+			var written sync.WaitGroup
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			lifetimeManager, conns := newBroadcastTestLifetimeManager(ctx, count, &written)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				written.Add(count)
+				broadcast(lifetimeManager, conns)
+				written.Wait()
+			}
+		})
+	}
+}
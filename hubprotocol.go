@@ -10,11 +10,12 @@ import (
 // If buf does not contain the whole message, it returns a nil message and complete false
 // WriteMessage writes a message to the specified writer
 // UnmarshalArgument() unmarshals a raw message depending of the specified value type into value
+// The messages are of the types HubMessage, InvocationMessage, StreamItemMessage, CompletionMessage,
+// CancelInvocationMessage and CloseMessage. The Arguments of a read InvocationMessage are passed to UnmarshalArgument.
 type HubProtocol interface {
 	ReadMessage(buf *bytes.Buffer) (interface{}, bool, error)
 	WriteMessage(message interface{}, writer io.Writer) error
 	UnmarshalArgument(argument interface{}, value interface{}) error
-	setDebugLogger(dbg StructuredLogger)
 }
 
 // Protocol
@@ -54,6 +55,18 @@ type closeMessage struct {
 	AllowReconnect bool   `json:"allowReconnect" msg:"allowReconnect"`
 }
 
+// ackMessage acknowledges all sequenced messages up to SequenceID, see StatefulReconnect
+type ackMessage struct {
+	Type       int    `json:"type" msg:"type"`
+	SequenceID uint64 `json:"sequenceId" msg:"sequenceId"`
+}
+
+// sequenceMessage is sent after a reconnect. SequenceID is the sequence id of the next sequenced message.
+type sequenceMessage struct {
+	Type       int    `json:"type" msg:"type"`
+	SequenceID uint64 `json:"sequenceId" msg:"sequenceId"`
+}
+
 type handshakeRequest struct {
 	Protocol string `json:"protocol" msg:"protocol"`
 	Version  int    `json:"version" msg:"version"`
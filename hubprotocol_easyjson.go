@@ -109,7 +109,80 @@ func (v *streamItemMessage) UnmarshalJSON(data []byte) error {
 func (v *streamItemMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2802b09fDecodeGithubComPhilippseithSignalr(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *invocationMessage) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *sequenceMessage) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "type":
+			out.Type = int(in.Int())
+		case "sequenceId":
+			out.SequenceID = uint64(in.Uint64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in sequenceMessage) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Type))
+	}
+	{
+		const prefix string = ",\"sequenceId\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.SequenceID))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v sequenceMessage) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v sequenceMessage) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *sequenceMessage) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *sequenceMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr1(l, v)
+}
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *invocationMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -196,7 +269,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in invocationMessage) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in invocationMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -257,27 +330,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v invocationMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr1(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v invocationMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr1(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *invocationMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr1(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *invocationMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr1(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr2(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *hubMessage) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr3(in *jlexer.Lexer, out *hubMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -308,7 +381,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in hubMessage) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr3(out *jwriter.Writer, in hubMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -323,27 +396,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v hubMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr2(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr3(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v hubMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr2(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr3(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *hubMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr2(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr3(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *hubMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr2(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr3(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr3(in *jlexer.Lexer, out *handshakeResponse) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr4(in *jlexer.Lexer, out *handshakeResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -374,7 +447,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr3(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr3(out *jwriter.Writer, in handshakeResponse) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr4(out *jwriter.Writer, in handshakeResponse) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -390,27 +463,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr3(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v handshakeResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr3(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v handshakeResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr3(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *handshakeResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr3(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *handshakeResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr3(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr4(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr4(in *jlexer.Lexer, out *handshakeRequest) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr5(in *jlexer.Lexer, out *handshakeRequest) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -443,7 +516,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr4(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr4(out *jwriter.Writer, in handshakeRequest) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr5(out *jwriter.Writer, in handshakeRequest) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -463,27 +536,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr4(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v handshakeRequest) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr4(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v handshakeRequest) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr4(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *handshakeRequest) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr4(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *handshakeRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr4(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr5(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr5(in *jlexer.Lexer, out *completionMessage) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr6(in *jlexer.Lexer, out *completionMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -526,7 +599,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr5(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr5(out *jwriter.Writer, in completionMessage) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr6(out *jwriter.Writer, in completionMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -562,27 +635,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr5(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v completionMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr5(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr6(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v completionMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr5(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr6(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *completionMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr5(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr6(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *completionMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr5(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr6(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr6(in *jlexer.Lexer, out *closeMessage) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr7(in *jlexer.Lexer, out *closeMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -617,7 +690,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr6(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr6(out *jwriter.Writer, in closeMessage) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr7(out *jwriter.Writer, in closeMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -642,27 +715,27 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr6(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v closeMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr6(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr7(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v closeMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr6(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr7(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *closeMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr6(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr7(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *closeMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr6(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr7(l, v)
 }
-func easyjson2802b09fDecodeGithubComPhilippseithSignalr7(in *jlexer.Lexer, out *cancelInvocationMessage) {
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr8(in *jlexer.Lexer, out *cancelInvocationMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -695,7 +768,7 @@ func easyjson2802b09fDecodeGithubComPhilippseithSignalr7(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson2802b09fEncodeGithubComPhilippseithSignalr7(out *jwriter.Writer, in cancelInvocationMessage) {
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr8(out *jwriter.Writer, in cancelInvocationMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -715,23 +788,96 @@ func easyjson2802b09fEncodeGithubComPhilippseithSignalr7(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v cancelInvocationMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr7(&w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr8(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v cancelInvocationMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2802b09fEncodeGithubComPhilippseithSignalr7(w, v)
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr8(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *cancelInvocationMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr7(&r, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr8(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *cancelInvocationMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2802b09fDecodeGithubComPhilippseithSignalr7(l, v)
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr8(l, v)
+}
+func easyjson2802b09fDecodeGithubComPhilippseithSignalr9(in *jlexer.Lexer, out *ackMessage) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "type":
+			out.Type = int(in.Int())
+		case "sequenceId":
+			out.SequenceID = uint64(in.Uint64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2802b09fEncodeGithubComPhilippseithSignalr9(out *jwriter.Writer, in ackMessage) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Type))
+	}
+	{
+		const prefix string = ",\"sequenceId\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.SequenceID))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ackMessage) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr9(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ackMessage) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2802b09fEncodeGithubComPhilippseithSignalr9(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ackMessage) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr9(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ackMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2802b09fDecodeGithubComPhilippseithSignalr9(l, v)
 }
@@ -0,0 +1,71 @@
+package signalr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// HubRouter serves many hubs, each on its own path, over one http.Handler.
+// Every hub gets its own Server with its own HubLifetimeManager and group space,
+// but all hubs share the options passed to NewHubRouter.
+type HubRouter interface {
+	http.Handler
+	// MapHub creates a Server for the hub given by one of the options UseHub, HubFactory or SimpleHubFactory
+	// and serves it on path. The shared options of the HubRouter are applied before the options passed here,
+	// so options passed here override the shared ones.
+	MapHub(path string, options ...func(Party) error) (Server, error)
+	// Server returns the Server mapped to path
+	Server(path string) (Server, bool)
+}
+
+// NewHubRouter creates a HubRouter. options are applied to all hubs mapped by this HubRouter.
+// Each hub counts its connections and negotiations on its own, so the limits of the options
+// MaxConnections, MaxConnectionsPerAddress, MaxConnectionsPerUser and MaxPendingNegotiations apply per hub.
+// E.g. with MaxConnectionsPerAddress(2) and three mapped hubs, one address can have six connections.
+func NewHubRouter(ctx context.Context, options ...func(Party) error) HubRouter {
+	return &hubRouter{
+		ctx:     ctx,
+		options: options,
+		mux:     http.NewServeMux(),
+		servers: make(map[string]Server),
+	}
+}
+
+type hubRouter struct {
+	ctx     context.Context
+	options []func(Party) error
+	mux     *http.ServeMux
+	mx      sync.Mutex
+	servers map[string]Server
+}
+
+func (h *hubRouter) MapHub(path string, options ...func(Party) error) (Server, error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if _, ok := h.servers[path]; ok {
+		return nil, fmt.Errorf("path %v is already mapped", path)
+	}
+	allOptions := make([]func(Party) error, 0, len(h.options)+len(options))
+	allOptions = append(allOptions, h.options...)
+	allOptions = append(allOptions, options...)
+	server, err := NewServer(h.ctx, allOptions...)
+	if err != nil {
+		return nil, err
+	}
+	mapHTTP(h.mux, path, server)
+	h.servers[path] = server
+	return server, nil
+}
+
+func (h *hubRouter) Server(path string) (Server, bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	server, ok := h.servers[path]
+	return server, ok
+}
+
+func (h *hubRouter) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.mux.ServeHTTP(writer, request)
+}
@@ -0,0 +1,83 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"os"
+)
+
+type greetHub struct {
+	Hub
+}
+
+func (g *greetHub) Greet(name string) string {
+	return "Hello " + name
+}
+
+var _ = Describe("HubRouter", func() {
+	Context("When two hubs are mapped", func() {
+		It("should serve both hubs on their paths with separate servers", func(done Done) {
+			logger := &nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}
+			router := NewHubRouter(context.TODO(), Logger(logger, false), HTTPTransports("WebSockets"))
+			addServer, err := router.MapHub("/add", SimpleHubFactory(&addHub{}))
+			Expect(err).NotTo(HaveOccurred())
+			greetServer, err := router.MapHub("/greet", SimpleHubFactory(&greetHub{}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addServer).NotTo(BeIdenticalTo(greetServer))
+			mapped, ok := router.Server("/greet")
+			Expect(ok).To(BeTrue())
+			Expect(mapped).To(BeIdenticalTo(greetServer))
+			Expect(addServer.(*server).groupManager).NotTo(BeIdenticalTo(greetServer.(*server).groupManager))
+			port := freePort()
+			go func() {
+				_ = http.ListenAndServe(fmt.Sprintf("127.0.0.1:%v", port), router)
+			}()
+			waitForPort(port)
+			addClient, err := NewHTTPClient(context.TODO(), fmt.Sprintf("http://127.0.0.1:%v/add", port), Logger(logger, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addClient.Start()).To(Succeed())
+			result := <-addClient.Invoke("Add2", 1)
+			Expect(result.Error).NotTo(HaveOccurred())
+			Expect(result.Value).To(Equal(json.Number("3")))
+			greetClient, err := NewHTTPClient(context.TODO(), fmt.Sprintf("http://127.0.0.1:%v/greet", port), Logger(logger, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(greetClient.Start()).To(Succeed())
+			result = <-greetClient.Invoke("Greet", "Go")
+			Expect(result.Error).NotTo(HaveOccurred())
+			Expect(result.Value).To(Equal("Hello Go"))
+			// Methods of the other hub are unknown
+			result = <-greetClient.Invoke("Add2", 1)
+			Expect(result.Error).To(HaveOccurred())
+			close(done)
+		}, 5.0)
+	})
+	Context("When options are given for one hub", func() {
+		It("should override the shared options", func() {
+			router := NewHubRouter(context.TODO(), HTTPTransports("WebSockets"))
+			server, err := router.MapHub("/sse", SimpleHubFactory(&addHub{}), HTTPTransports("ServerSentEvents"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.availableTransports()).To(Equal([]string{"ServerSentEvents"}))
+		})
+	})
+	Context("When a path is mapped twice", func() {
+		It("should return an error", func() {
+			router := NewHubRouter(context.TODO())
+			_, err := router.MapHub("/add", SimpleHubFactory(&addHub{}))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = router.MapHub("/add", SimpleHubFactory(&greetHub{}))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When no hub is given", func() {
+		It("should return an error", func() {
+			router := NewHubRouter(context.TODO())
+			_, err := router.MapHub("/none")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package signalr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LimitPolicy defines how a Party reacts to an invocation which exceeds an invocation limit
+type LimitPolicy int
+
+const (
+	// LimitQueue stops processing messages from the connection until the invocation is within the limit.
+	// Note that this also delays stream items and completions sent by the other Party.
+	LimitQueue LimitPolicy = iota
+	// LimitReject rejects the invocation. The other Party receives a completion with error.
+	LimitReject
+	// LimitCloseConnection closes the connection. The other Party receives a close message with error.
+	LimitCloseConnection
+)
+
+// rateLimit is the configuration of a token bucket
+type rateLimit struct {
+	perSecond float64
+	burst     uint
+	policy    LimitPolicy
+}
+
+// invocationLimits are the limits for the invocations one connection can send
+type invocationLimits struct {
+	maxParallel    uint
+	parallelPolicy LimitPolicy
+	rate           *rateLimit
+	methodRates    map[string]*rateLimit
+}
+
+// tokenBucket is a token bucket rate limiter. It is only used by the loop of one connection, so it is not synchronized
+type tokenBucket struct {
+	limit  *rateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit *rateLimit) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.burst),
+		last:   time.Now(),
+	}
+}
+
+// take takes a token from the bucket. If there is none, it returns the time until the next token is available
+func (b *tokenBucket) take() (wait time.Duration) {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.perSecond
+	if b.tokens > float64(b.limit.burst) {
+		b.tokens = float64(b.limit.burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.perSecond * float64(time.Second))
+}
+
+// invocationLimiter applies the invocationLimits to the invocations of one connection
+type invocationLimiter struct {
+	limits        *invocationLimits
+	parallel      chan struct{}
+	bucket        *tokenBucket
+	methodBuckets map[string]*tokenBucket
+}
+
+func newInvocationLimiter(limits *invocationLimits) *invocationLimiter {
+	l := &invocationLimiter{
+		limits:        limits,
+		methodBuckets: make(map[string]*tokenBucket),
+	}
+	if limits.maxParallel > 0 {
+		l.parallel = make(chan struct{}, limits.maxParallel)
+	}
+	if limits.rate != nil {
+		l.bucket = newTokenBucket(limits.rate)
+	}
+	return l
+}
+
+// invocationLimitError is returned when an invocation exceeds a limit
+type invocationLimitError struct {
+	text   string
+	policy LimitPolicy
+}
+
+func (e *invocationLimitError) Error() string {
+	return e.text
+}
+
+// takeToken takes a token for an invocation of method. With LimitQueue, it waits until a token is available or done
+// is closed.
+func (l *invocationLimiter) takeToken(method string, done <-chan struct{}) *invocationLimitError {
+	if l.bucket != nil {
+		if err := l.wait(l.bucket, "invocation rate limit exceeded", done); err != nil {
+			return err
+		}
+	}
+	method = strings.ToLower(method)
+	if limit, ok := l.limits.methodRates[method]; ok {
+		bucket, ok := l.methodBuckets[method]
+		if !ok {
+			bucket = newTokenBucket(limit)
+			l.methodBuckets[method] = bucket
+		}
+		return l.wait(bucket, fmt.Sprintf("invocation rate limit of method %v exceeded", method), done)
+	}
+	return nil
+}
+
+func (l *invocationLimiter) wait(bucket *tokenBucket, text string, done <-chan struct{}) *invocationLimitError {
+	for {
+		wait := bucket.take()
+		if wait == 0 {
+			return nil
+		}
+		if bucket.limit.policy != LimitQueue {
+			return &invocationLimitError{text: text, policy: bucket.limit.policy}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return &invocationLimitError{text: text, policy: LimitCloseConnection}
+		}
+	}
+}
+
+// acquire acquires a slot for a parallel invocation. With LimitQueue, it waits until a slot is free or done is closed.
+// release must be called when the invocation has ended.
+func (l *invocationLimiter) acquire(done <-chan struct{}) (release func(), err *invocationLimitError) {
+	if l.parallel == nil {
+		return func() {}, nil
+	}
+	release = func() { <-l.parallel }
+	if l.limits.parallelPolicy == LimitQueue {
+		select {
+		case l.parallel <- struct{}{}:
+			return release, nil
+		case <-done:
+			return nil, &invocationLimitError{text: "maximum parallel invocations exceeded", policy: LimitCloseConnection}
+		}
+	}
+	select {
+	case l.parallel <- struct{}{}:
+		return release, nil
+	default:
+		return nil, &invocationLimitError{text: "maximum parallel invocations exceeded", policy: l.limits.parallelPolicy}
+	}
+}
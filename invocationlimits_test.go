@@ -0,0 +1,123 @@
+package signalr
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"time"
+)
+
+// connectLimits connects to a server with the addHub and a "block" handler, which returns when release is closed
+func connectLimits(release chan struct{}, options ...func(Party) error) *testingConnection {
+	server, err := NewServer(context.TODO(), append([]func(Party) error{SimpleHubFactory(&addHub{}),
+		Logger(log.NewLogfmtLogger(os.Stderr), false)}, options...)...)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(server.Handle("block", func() int {
+		<-release
+		return 1
+	})).To(Succeed())
+	conn := newTestingConnectionForServer()
+	go server.ServeConnection(conn)
+	return conn
+}
+
+// receiveCompletions receives count completions and returns them by their invocationID
+func receiveCompletions(conn *testingConnection, count int) map[string]completionMessage {
+	completions := make(map[string]completionMessage)
+	for len(completions) < count {
+		completion := receiveCompletion(conn)
+		completions[completion.InvocationID] = completion
+	}
+	return completions
+}
+
+var _ = Describe("Invocation limits", func() {
+	Context("When the maximum number of parallel invocations is reached", func() {
+		It("should reject further invocations with LimitReject", func(done Done) {
+			release := make(chan struct{})
+			conn := connectLimits(release, MaximumParallelInvocationsPerClient(1, LimitReject))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"block"}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"block"}`)
+			completion := receiveCompletion(conn)
+			Expect(completion.InvocationID).To(Equal("2"))
+			Expect(completion.Error).To(Equal("maximum parallel invocations exceeded"))
+			close(release)
+			completion = receiveCompletion(conn)
+			Expect(completion.InvocationID).To(Equal("1"))
+			Expect(completion.Result).To(Equal(1.0))
+			close(done)
+		}, 2.0)
+		It("should delay further invocations with LimitQueue", func(done Done) {
+			release := make(chan struct{})
+			conn := connectLimits(release, MaximumParallelInvocationsPerClient(1, LimitQueue))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"block"}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"add2","arguments":[1]}`)
+			Consistently(conn.ReceiveChan(), 100*time.Millisecond).ShouldNot(Receive())
+			close(release)
+			completions := receiveCompletions(conn, 2)
+			Expect(completions["1"].Result).To(Equal(1.0))
+			Expect(completions["2"].Result).To(Equal(3.0))
+			close(done)
+		}, 2.0)
+	})
+	Context("When the invocation rate limit is exceeded", func() {
+		It("should reject the invocations beyond the burst with LimitReject", func(done Done) {
+			conn := connectLimits(nil, InvocationRateLimit(1, 2, LimitReject))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"add2","arguments":[1]}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"add2","arguments":[2]}`)
+			conn.ClientSend(`{"type":1,"invocationId":"3","target":"add2","arguments":[3]}`)
+			completions := receiveCompletions(conn, 3)
+			Expect(completions["1"].Result).To(Equal(3.0))
+			Expect(completions["2"].Result).To(Equal(4.0))
+			Expect(completions["3"].Error).To(Equal("invocation rate limit exceeded"))
+			close(done)
+		}, 2.0)
+		It("should close the connection with LimitCloseConnection when the method rate limit is exceeded", func(done Done) {
+			conn := connectLimits(nil, MethodInvocationRateLimit("Add2", 1, 1, LimitCloseConnection))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"add2","arguments":[1]}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"add2","arguments":[2]}`)
+			for {
+				if message, ok := (<-conn.ReceiveChan()).(closeMessage); ok {
+					Expect(message.Error).To(ContainSubstring("invocation rate limit of method add2 exceeded"))
+					break
+				}
+			}
+			close(done)
+		}, 2.0)
+		It("should limit the invocations by an alias of the method", func(done Done) {
+			conn := connectLimits(nil, MethodInvocationRateLimit("Add2", 1, 1, LimitCloseConnection),
+				HubMethodAlias("plus2", "Add2"))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"add2","arguments":[1]}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"plus2","arguments":[2]}`)
+			for {
+				if message, ok := (<-conn.ReceiveChan()).(closeMessage); ok {
+					Expect(message.Error).To(ContainSubstring("invocation rate limit of method add2 exceeded"))
+					break
+				}
+			}
+			close(done)
+		}, 2.0)
+		It("should delay the invocations beyond the burst with LimitQueue", func(done Done) {
+			conn := connectLimits(nil, InvocationRateLimit(10, 1, LimitQueue))
+			start := time.Now()
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"add2","arguments":[1]}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"add2","arguments":[2]}`)
+			completions := receiveCompletions(conn, 2)
+			Expect(completions["2"].Result).To(Equal(4.0))
+			Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+			close(done)
+		}, 2.0)
+	})
+	Context("When the options are invalid", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), InvocationRateLimit(0, 1, LimitReject))
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MethodInvocationRateLimit("add2", 1, 0, LimitReject))
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MaximumParallelInvocationsPerClient(1, LimitPolicy(7)))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -9,13 +9,15 @@ import (
 
 type invokeClient struct {
 	mx                 sync.Mutex
+	protocol           HubProtocol
 	resultChans        map[string]invokeResult
 	chanReceiveTimeout time.Duration
 }
 
-func newInvokeClient(chanReceiveTimeout time.Duration) *invokeClient {
+func newInvokeClient(protocol HubProtocol, chanReceiveTimeout time.Duration) *invokeClient {
 	return &invokeClient{
 		mx:                 sync.Mutex{},
+		protocol:           protocol,
 		resultChans:        make(map[string]invokeResult),
 		chanReceiveTimeout: chanReceiveTimeout,
 	}
@@ -87,9 +89,17 @@ func (i *invokeClient) receiveCompletionItem(completion completionMessage) error
 			}
 		}
 		if completion.Result != nil {
+			result := completion.Result
+			// The type of the result is unknown, so numbers are kept exactly as json.Number
+			if value, ok, err := unmarshalValue(i.protocol, result, interfaceType); ok {
+				if err != nil {
+					return err
+				}
+				result = value.Interface()
+			}
 			done := make(chan struct{})
 			go func() {
-				ir.resultChan <- completion.Result
+				ir.resultChan <- result
 				done <- struct{}{}
 			}()
 			select {
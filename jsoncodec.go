@@ -0,0 +1,120 @@
+package signalr
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// JSONCodecOptions configure how the JSONHubProtocol encodes and decodes arguments, results and stream items.
+// Received values are kept as raw JSON until the type of the hub method parameter or channel they belong to is
+// known, so numbers like 64 bit IDs are decoded without the loss of precision of float64.
+type JSONCodecOptions struct {
+	// UseNumber decodes numbers into json.Number instead of float64 if the target type is interface{},
+	// e.g. for hub method parameters of type interface{} or map[string]interface{}.
+	// The results of Client.Invoke and the items of Client.PullStream always keep numbers as json.Number.
+	UseNumber bool
+	// DisallowUnknownFields rejects values with fields which do not exist in the target struct
+	DisallowUnknownFields bool
+	// Marshal replaces json.Marshal for arguments, results and stream items
+	Marshal func(value interface{}) ([]byte, error)
+	// Unmarshal replaces the decoding of arguments, results and stream items. If it is set,
+	// UseNumber and DisallowUnknownFields are ignored.
+	Unmarshal func(data []byte, value interface{}) error
+}
+
+func (o *JSONCodecOptions) unmarshal(data []byte, value interface{}) error {
+	if o.Unmarshal != nil {
+		return o.Unmarshal(data, value)
+	}
+	if !o.UseNumber && !o.DisallowUnknownFields {
+		return json.Unmarshal(data, value)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if o.UseNumber {
+		decoder.UseNumber()
+	}
+	if o.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(value)
+}
+
+// unmarshalUntyped decodes data whose type is unknown. Numbers are kept as json.Number, so they can be
+// converted without loss of precision when the type is known.
+func (o *JSONCodecOptions) unmarshalUntyped(data []byte) (interface{}, error) {
+	var value interface{}
+	if o.Unmarshal != nil {
+		err := o.Unmarshal(data, &value)
+		return value, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+// marshalValues marshals the values in message with the custom Marshal function
+func (o *JSONCodecOptions) marshalValues(message interface{}) (interface{}, error) {
+	if o.Marshal == nil {
+		return message, nil
+	}
+	switch m := message.(type) {
+	case invocationMessage:
+		arguments := make([]interface{}, len(m.Arguments))
+		for i, argument := range m.Arguments {
+			raw, err := o.marshal(argument)
+			if err != nil {
+				return nil, err
+			}
+			arguments[i] = raw
+		}
+		m.Arguments = arguments
+		return m, nil
+	case streamItemMessage:
+		raw, err := o.marshal(m.Item)
+		m.Item = raw
+		return m, err
+	case completionMessage:
+		if m.Result == nil {
+			return m, nil
+		}
+		raw, err := o.marshal(m.Result)
+		m.Result = raw
+		return m, err
+	default:
+		return message, nil
+	}
+}
+
+func (o *JSONCodecOptions) marshal(value interface{}) (json.RawMessage, error) {
+	data, err := o.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// unmarshalValue decodes a received stream item or completion result into a value of type t.
+// ok is false if the protocol has already decoded the value without knowing t.
+// If t is interface{}, the JSONHubProtocol decodes numbers into json.Number.
+func unmarshalValue(protocol HubProtocol, value interface{}, t reflect.Type) (v reflect.Value, ok bool, err error) {
+	raw, ok := value.(json.RawMessage)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	if j, isJSON := protocol.(*JSONHubProtocol); isJSON && t == interfaceType {
+		var untyped interface{}
+		if untyped, err = j.codec.unmarshalUntyped(raw); err != nil {
+			return reflect.Value{}, true, &jsonError{string(raw), err}
+		}
+		return reflect.ValueOf(&untyped).Elem(), true, nil
+	}
+	ptr := reflect.New(t)
+	if err = protocol.UnmarshalArgument(raw, ptr.Interface()); err != nil {
+		return reflect.Value{}, true, err
+	}
+	return ptr.Elem(), true, nil
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
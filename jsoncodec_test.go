@@ -0,0 +1,154 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"strconv"
+)
+
+// bigID can not be represented exactly as float64
+const bigID int64 = 1<<53 + 1
+
+type idHub struct {
+	Hub
+	uploaded chan int64
+}
+
+func (i *idHub) EchoID(id int64) int64 {
+	return id
+}
+
+func (i *idHub) StreamIDs(id int64) <-chan int64 {
+	ch := make(chan int64, 2)
+	ch <- id
+	ch <- id + 2
+	close(ch)
+	return ch
+}
+
+func (i *idHub) UploadIDs(ids <-chan int64) {
+	for id := range ids {
+		i.uploaded <- id
+	}
+}
+
+func (i *idHub) FormatAny(value interface{}) string {
+	return fmt.Sprint(value)
+}
+
+type idRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+func (i *idHub) RangeSize(r idRange) int64 {
+	return r.To - r.From
+}
+
+var _ = Describe("JSONCodec", func() {
+	// start returns locals, so connections of a previous spec which are still ending do not see the next ones
+	start := func(serverCodec JSONCodecOptions, clientCodec JSONCodecOptions) (Server, Client, chan int64) {
+		uploaded := make(chan int64, 10)
+		server, err := NewServer(context.TODO(),
+			HubFactory(func() HubInterface { return &idHub{uploaded: uploaded} }), JSONCodec(serverCodec),
+			Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+		Expect(err).NotTo(HaveOccurred())
+		cliConn, srvConn := newClientServerConnections()
+		go server.ServeConnection(srvConn)
+		client, err := NewClient(context.TODO(), cliConn, JSONCodec(clientCodec),
+			Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Start()).To(Succeed())
+		return server, client, uploaded
+	}
+	stop := func(server Server, client Client) {
+		Expect(client.Stop()).To(Succeed())
+		server.cancel()
+	}
+	Context("When the server uses UseNumber", func() {
+		It("should decode numbers in interface{} parameters exactly", func(done Done) {
+			server, client, _ := start(JSONCodecOptions{UseNumber: true}, JSONCodecOptions{})
+			Expect(<-client.Invoke("FormatAny", bigID)).To(Equal(InvokeResult{Value: fmt.Sprint(bigID)}))
+			stop(server, client)
+			close(done)
+		}, 2.0)
+	})
+	Context("When the client uses the default codec", func() {
+		It("should return 64 bit IDs exactly as json.Number", func(done Done) {
+			server, client, _ := start(JSONCodecOptions{}, JSONCodecOptions{})
+			result := <-client.Invoke("EchoID", bigID)
+			Expect(result).To(Equal(InvokeResult{Value: json.Number(fmt.Sprint(bigID))}))
+			id, err := result.Value.(json.Number).Int64()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(bigID))
+			stop(server, client)
+			close(done)
+		}, 2.0)
+		It("should stream 64 bit IDs exactly as json.Number", func(done Done) {
+			server, client, _ := start(JSONCodecOptions{}, JSONCodecOptions{})
+			ch := client.PullStream("StreamIDs", bigID)
+			Expect(<-ch).To(Equal(InvokeResult{Value: json.Number(fmt.Sprint(bigID))}))
+			Expect(<-ch).To(Equal(InvokeResult{Value: json.Number(fmt.Sprint(bigID + 2))}))
+			stop(server, client)
+			close(done)
+		}, 2.0)
+	})
+	Context("When the client pushes 64 bit IDs", func() {
+		It("should decode them exactly into the channel type of the hub method", func(done Done) {
+			server, client, uploaded := start(JSONCodecOptions{}, JSONCodecOptions{})
+			ids := make(chan int64, 2)
+			ids <- bigID
+			ids <- bigID + 2
+			close(ids)
+			_ = client.PushStreams("UploadIDs", ids)
+			Expect(<-uploaded).To(Equal(bigID))
+			Expect(<-uploaded).To(Equal(bigID + 2))
+			stop(server, client)
+			close(done)
+		})
+	})
+	Context("When the server uses DisallowUnknownFields", func() {
+		It("should reject arguments with unknown fields", func(done Done) {
+			server, client, _ := start(JSONCodecOptions{DisallowUnknownFields: true}, JSONCodecOptions{})
+			Expect(<-client.Invoke("RangeSize", idRange{From: 1, To: 3})).To(Equal(InvokeResult{Value: json.Number("2")}))
+			result := <-client.Invoke("RangeSize", map[string]int64{"from": 1, "to": 3, "step": 1})
+			Expect(result.Error).To(HaveOccurred())
+			stop(server, client)
+			close(done)
+		})
+	})
+	Context("When a custom Marshal and Unmarshal are set", func() {
+		It("should use them for arguments and results", func(done Done) {
+			// IDs are sent as strings, like JavaScript clients often do
+			codec := JSONCodecOptions{
+				Marshal: func(value interface{}) ([]byte, error) {
+					if id, ok := value.(int64); ok {
+						return json.Marshal(strconv.FormatInt(id, 10))
+					}
+					return json.Marshal(value)
+				},
+				Unmarshal: func(data []byte, value interface{}) error {
+					if id, ok := value.(*int64); ok {
+						var s string
+						if err := json.Unmarshal(data, &s); err != nil {
+							return err
+						}
+						var err error
+						*id, err = strconv.ParseInt(s, 10, 64)
+						return err
+					}
+					return json.Unmarshal(data, value)
+				},
+			}
+			server, client, _ := start(codec, codec)
+			Expect(<-client.Invoke("EchoID", bigID)).To(Equal(InvokeResult{Value: fmt.Sprint(bigID)}))
+			stop(server, client)
+			close(done)
+		})
+	})
+})
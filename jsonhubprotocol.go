@@ -12,10 +12,11 @@ import (
 	"reflect"
 )
 
-// JSONHubProtocol is the JSON based SignalR protocol
+// JSONHubProtocol is the JSON based SignalR protocol.
+// WriteMessage is safe for concurrent use, each message is encoded with its own writer.
 type JSONHubProtocol struct {
-	dbg        log.Logger
-	easyWriter jwriter.Writer
+	dbg   log.Logger
+	codec JSONCodecOptions
 }
 
 // Protocol specific message for correct unmarshaling of Arguments
@@ -27,6 +28,20 @@ type jsonInvocationMessage struct {
 	StreamIds    []string          `json:"streamIds,omitempty"`
 }
 
+// Protocol specific messages which keep Item and Result raw until their target type is known
+type jsonStreamItemMessage struct {
+	Type         int             `json:"type"`
+	InvocationID string          `json:"invocationId"`
+	Item         json.RawMessage `json:"item"`
+}
+
+type jsonCompletionMessage struct {
+	Type         int             `json:"type"`
+	InvocationID string          `json:"invocationId"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
 type jsonError struct {
 	raw string
 	err error
@@ -38,7 +53,7 @@ func (j *jsonError) Error() string {
 
 // UnmarshalArgument unmarshals a json.RawMessage depending of the specified value type into value
 func (j *JSONHubProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
-	if err := json.Unmarshal(argument.(json.RawMessage), value); err != nil {
+	if err := j.codec.unmarshal(argument.(json.RawMessage), value); err != nil {
 		return &jsonError{string(argument.(json.RawMessage)), err}
 	}
 	_ = j.dbg.Log(evt, "UnmarshalArgument",
@@ -48,14 +63,13 @@ func (j *JSONHubProtocol) UnmarshalArgument(argument interface{}, value interfac
 }
 
 // ReadMessage reads a JSON message from buf and returns the message if the buf contained one completely.
-// If buf does not contain the whole message, it returns a nil message and complete false
+// If buf does not contain the whole message, it returns a nil message and complete false and leaves buf unchanged
 func (j *JSONHubProtocol) ReadMessage(buf *bytes.Buffer) (m interface{}, complete bool, err error) {
 	data, err := parseTextMessageFormat(buf)
 	switch {
 	case errors.Is(err, io.EOF):
 		return nil, false, err
-		// Other errors never happen, because parseTextMessageFormat will only return err
-		// from bytes.Buffer.ReadBytes() which is always io.EOF or nil
+		// Other errors never happen, because parseTextMessageFormat will only return io.EOF or nil
 	}
 
 	message := hubMessage{}
@@ -84,16 +98,27 @@ func (j *JSONHubProtocol) ReadMessage(buf *bytes.Buffer) (m interface{}, complet
 		}
 		return invocation, true, err
 	case 2:
-		streamItem := streamItemMessage{}
-		if err = streamItem.UnmarshalJSON(data); err != nil {
+		jsonStreamItem := jsonStreamItemMessage{}
+		if err = jsonStreamItem.UnmarshalJSON(data); err != nil {
 			err = &jsonError{string(data), err}
 		}
+		streamItem := streamItemMessage{
+			Type:         jsonStreamItem.Type,
+			InvocationID: jsonStreamItem.InvocationID,
+			Item:         rawValue(jsonStreamItem.Item),
+		}
 		return streamItem, true, err
 	case 3:
-		completion := completionMessage{}
-		if err = completion.UnmarshalJSON(data); err != nil {
+		jsonCompletion := jsonCompletionMessage{}
+		if err = jsonCompletion.UnmarshalJSON(data); err != nil {
 			err = &jsonError{string(data), err}
 		}
+		completion := completionMessage{
+			Type:         jsonCompletion.Type,
+			InvocationID: jsonCompletion.InvocationID,
+			Result:       rawValue(jsonCompletion.Result),
+			Error:        jsonCompletion.Error,
+		}
 		return completion, true, err
 	case 5:
 		invocation := cancelInvocationMessage{}
@@ -107,28 +132,59 @@ func (j *JSONHubProtocol) ReadMessage(buf *bytes.Buffer) (m interface{}, complet
 			err = &jsonError{string(data), err}
 		}
 		return cm, true, err
+	case 8:
+		ack := ackMessage{}
+		if err = ack.UnmarshalJSON(data); err != nil {
+			err = &jsonError{string(data), err}
+		}
+		return ack, true, err
+	case 9:
+		sequence := sequenceMessage{}
+		if err = sequence.UnmarshalJSON(data); err != nil {
+			err = &jsonError{string(data), err}
+		}
+		return sequence, true, err
 	default:
 		return message, true, nil
 	}
 }
 
+// rawValue returns nil for missing and null values, so they are not mistaken for results
+func rawValue(raw json.RawMessage) interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// parseTextMessageFormat reads one record separator terminated message from buf.
+// If buf does not contain a complete message, io.EOF is returned and buf is left unchanged.
 func parseTextMessageFormat(buf *bytes.Buffer) ([]byte, error) {
 	// 30 = ASCII record separator
-	data, err := buf.ReadBytes(30)
-
-	if err != nil {
-		return data, err
+	i := bytes.IndexByte(buf.Bytes(), 30)
+	if i < 0 {
+		return nil, io.EOF
 	}
+	data := buf.Next(i + 1)
 	// Remove the delimiter
-	return data[0 : len(data)-1], err
+	return data[0:i], nil
 }
 
-// WriteMessage writes a message as JSON to the specified writer
+// WriteMessage writes a message as JSON to the specified writer.
+// The message including its record separator is written with one call to writer.Write
 func (j *JSONHubProtocol) WriteMessage(message interface{}, writer io.Writer) error {
+	message, err := j.codec.marshalValues(message)
+	if err != nil {
+		return err
+	}
 	if em, ok := message.(easyjson.Marshaler); ok {
-		em.MarshalEasyJSON(&j.easyWriter)
-		j.easyWriter.RawByte(30)
-		b := j.easyWriter.Buffer.BuildBytes()
+		easyWriter := jwriter.Writer{}
+		em.MarshalEasyJSON(&easyWriter)
+		easyWriter.RawByte(30)
+		if easyWriter.Error != nil {
+			return easyWriter.Error
+		}
+		b := easyWriter.Buffer.BuildBytes()
 		_ = j.dbg.Log(evt, "write", msg, string(b))
 		_, err := writer.Write(b)
 		return err
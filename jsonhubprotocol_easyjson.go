@@ -17,7 +17,89 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson766e99b1DecodeGithubComPhilippseithSignalr(in *jlexer.Lexer, out *jsonInvocationMessage) {
+func easyjson766e99b1DecodeGithubComPhilippseithSignalr(in *jlexer.Lexer, out *jsonStreamItemMessage) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "type":
+			out.Type = int(in.Int())
+		case "invocationId":
+			out.InvocationID = string(in.String())
+		case "item":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Item).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson766e99b1EncodeGithubComPhilippseithSignalr(out *jwriter.Writer, in jsonStreamItemMessage) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Type))
+	}
+	{
+		const prefix string = ",\"invocationId\":"
+		out.RawString(prefix)
+		out.String(string(in.InvocationID))
+	}
+	{
+		const prefix string = ",\"item\":"
+		out.RawString(prefix)
+		out.Raw((in.Item).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v jsonStreamItemMessage) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v jsonStreamItemMessage) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *jsonStreamItemMessage) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *jsonStreamItemMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr(l, v)
+}
+func easyjson766e99b1DecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *jsonInvocationMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -100,7 +182,7 @@ func easyjson766e99b1DecodeGithubComPhilippseithSignalr(in *jlexer.Lexer, out *j
 		in.Consumed()
 	}
 }
-func easyjson766e99b1EncodeGithubComPhilippseithSignalr(out *jwriter.Writer, in jsonInvocationMessage) {
+func easyjson766e99b1EncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in jsonInvocationMessage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -155,27 +237,27 @@ func easyjson766e99b1EncodeGithubComPhilippseithSignalr(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v jsonInvocationMessage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr(&w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v jsonInvocationMessage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr(w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *jsonInvocationMessage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr(&r, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *jsonInvocationMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr(l, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr1(l, v)
 }
-func easyjson766e99b1DecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *jsonError) {
+func easyjson766e99b1DecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *jsonError) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -204,7 +286,7 @@ func easyjson766e99b1DecodeGithubComPhilippseithSignalr1(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson766e99b1EncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in jsonError) {
+func easyjson766e99b1EncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in jsonError) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -214,27 +296,27 @@ func easyjson766e99b1EncodeGithubComPhilippseithSignalr1(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v jsonError) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr1(&w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v jsonError) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr1(w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *jsonError) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr1(&r, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *jsonError) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr1(l, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr2(l, v)
 }
-func easyjson766e99b1DecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *JSONHubProtocol) {
+func easyjson766e99b1DecodeGithubComPhilippseithSignalr3(in *jlexer.Lexer, out *jsonCompletionMessage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -253,6 +335,16 @@ func easyjson766e99b1DecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *
 			continue
 		}
 		switch key {
+		case "type":
+			out.Type = int(in.Int())
+		case "invocationId":
+			out.InvocationID = string(in.String())
+		case "result":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Result).UnmarshalJSON(data))
+			}
+		case "error":
+			out.Error = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -263,7 +355,86 @@ func easyjson766e99b1DecodeGithubComPhilippseithSignalr2(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson766e99b1EncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in JSONHubProtocol) {
+func easyjson766e99b1EncodeGithubComPhilippseithSignalr3(out *jwriter.Writer, in jsonCompletionMessage) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Type))
+	}
+	{
+		const prefix string = ",\"invocationId\":"
+		out.RawString(prefix)
+		out.String(string(in.InvocationID))
+	}
+	if len(in.Result) != 0 {
+		const prefix string = ",\"result\":"
+		out.RawString(prefix)
+		out.Raw((in.Result).MarshalJSON())
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v jsonCompletionMessage) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v jsonCompletionMessage) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *jsonCompletionMessage) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *jsonCompletionMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr3(l, v)
+}
+func easyjson766e99b1DecodeGithubComPhilippseithSignalr4(in *jlexer.Lexer, out *JSONHubProtocol) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson766e99b1EncodeGithubComPhilippseithSignalr4(out *jwriter.Writer, in JSONHubProtocol) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -273,23 +444,23 @@ func easyjson766e99b1EncodeGithubComPhilippseithSignalr2(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v JSONHubProtocol) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr2(&w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v JSONHubProtocol) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson766e99b1EncodeGithubComPhilippseithSignalr2(w, v)
+	easyjson766e99b1EncodeGithubComPhilippseithSignalr4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *JSONHubProtocol) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr2(&r, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *JSONHubProtocol) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson766e99b1DecodeGithubComPhilippseithSignalr2(l, v)
+	easyjson766e99b1DecodeGithubComPhilippseithSignalr4(l, v)
 }
@@ -3,15 +3,14 @@ package signalr
 import (
 	"errors"
 	"fmt"
-	"github.com/rotisserie/eris"
 	"reflect"
 	"runtime/debug"
-	"strings"
 	"time"
 )
 
 type loop struct {
 	party        Party
+	conn         Connection
 	info         StructuredLogger
 	dbg          StructuredLogger
 	protocol     HubProtocol
@@ -19,31 +18,42 @@ type loop struct {
 	invokeClient *invokeClient
 	streamer     *streamer
 	streamClient *streamClient
+	limiter      *invocationLimiter
+	ordered      orderedExecutor
 }
 
-func newLoop(p Party, conn Connection, protocol HubProtocol) *loop {
-	protocol = reflect.New(reflect.ValueOf(protocol).Elem().Type()).Interface().(HubProtocol)
-	_, dbg := p.loggers()
-	protocol.setDebugLogger(dbg)
+// newLoop creates the loop for a connection. received is the data which was read after the handshake.
+func newLoop(p Party, conn Connection, protocol HubProtocol, received []byte) *loop {
+	if loggable, ok := protocol.(debugLoggable); ok {
+		_, dbg := p.loggers()
+		loggable.setDebugLogger(dbg)
+	}
+	if jsonProtocol, ok := protocol.(*JSONHubProtocol); ok {
+		jsonProtocol.codec = *p.jsonCodec()
+	}
 	pInfo, pDbg := p.prefixLoggers(conn.ConnectionID())
-	hubConn := newHubConnection(conn, protocol, p.maximumReceiveMessageSize(), pInfo)
+	hubConn := newHubConnection(conn, protocol, p.maximumReceiveMessageSize(),
+		outboundQueueConfig{
+			capacity:     p.outboundQueueCapacity(),
+			policy:       p.outboundOverflowPolicy(),
+			blockTimeout: p.outboundBlockTimeout(),
+			ttl:          p.outboundMessageTTL(),
+			dropCounter:  p.outboundDropCounter(),
+		}, received, pInfo)
 	return &loop{
 		party:        p,
+		conn:         conn,
 		protocol:     protocol,
 		hubConn:      hubConn,
-		invokeClient: newInvokeClient(p.chanReceiveTimeout()),
+		invokeClient: newInvokeClient(protocol, p.chanReceiveTimeout()),
 		streamer:     newStreamer(hubConn, pInfo),
-		streamClient: newStreamClient(p.chanReceiveTimeout(), p.streamBufferCapacity()),
+		streamClient: newStreamClient(protocol, p.chanReceiveTimeout(), p.streamBufferCapacity()),
+		limiter:      newInvocationLimiter(p.invocationLimits()),
 		info:         pInfo,
 		dbg:          pDbg,
 	}
 }
 
-type loopEvent struct {
-	message interface{}
-	err     error
-}
-
 // Run runs the loop. After the startup sequence is done, this is signaled over the started channel.
 // Callers should pass a channel with buffer size 1 to allow the loop to run without waiting for the caller.
 func (l *loop) Run(started chan struct{}) {
@@ -52,87 +62,124 @@ func (l *loop) Run(started chan struct{}) {
 	close(started)
 	// Process messages
 	var err error
-msgLoop:
-	for {
-		ch := make(chan loopEvent, 1)
-		go func() {
-			message, err := l.receive()
-			ch <- loopEvent{
-				message: message,
-				err:     err,
+	var closedByOtherParty bool
+	recvCh := l.hubConn.Receive()
+	keepAlive := time.NewTimer(l.party.keepAliveInterval())
+	defer keepAlive.Stop()
+	timeout := time.NewTimer(l.party.timeout())
+	defer timeout.Stop()
+	for err == nil {
+		select {
+		case recv := <-recvCh:
+			if err = recv.err; err != nil {
+				_ = l.info.Log(evt, msgRecv, "error", err, react, "close connection")
+				break
 			}
-			close(ch)
-		}()
-	pingLoop:
-		for {
-			select {
-			case evt := <-ch:
-				err = evt.err
-				if err == nil {
-					switch message := evt.message.(type) {
-					case invocationMessage:
-						l.handleInvocationMessage(message)
-					case cancelInvocationMessage:
-						_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(message))
-						l.streamer.Stop(message.InvocationID)
-					case streamItemMessage:
-						err = l.handleStreamItemMessage(message)
-					case completionMessage:
-						err = l.handleCompletionMessage(message)
-					case closeMessage:
-						_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(message))
-						// Bogus error to break the msgLoop
-						err = errors.New("")
-					case hubMessage:
-						// Mostly ping
-						err = l.handleOtherMessage(message)
-						// No default case necessary, because the protocol would return either a hubMessage or an error
-					}
-				}
-				break pingLoop
-			case <-time.After(l.party.keepAliveInterval()):
-				// Send ping only when there was no write in the keepAliveInterval before
-				if time.Since(l.hubConn.LastWriteStamp()) > l.party.keepAliveInterval() {
-					_ = l.hubConn.Ping()
-				}
-				// Don't break the pingLoop, it exists for this case
-			case <-time.After(l.party.timeout()):
-				err = fmt.Errorf("client timeout interval elapsed (%v)", l.party.timeout())
-				break pingLoop
-			case <-l.hubConn.Context().Done():
-				err = eris.Wrap(l.hubConn.Context().Err(), "hubConnection canceled")
-				break pingLoop
+			// Any message from the other Party is a sign of life
+			resetTimer(timeout, l.party.timeout())
+			switch message := recv.message.(type) {
+			case invocationMessage:
+				err = l.handleInvocationMessage(message)
+			case cancelInvocationMessage:
+				_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(message))
+				l.streamer.Stop(message.InvocationID)
+			case streamItemMessage:
+				err = l.handleStreamItemMessage(message)
+			case completionMessage:
+				err = l.handleCompletionMessage(message)
+			case closeMessage:
+				_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(message))
+				closedByOtherParty = true
+				// Bogus error to break the loop
+				err = errors.New("")
+			case ackMessage, sequenceMessage:
+				// Without stateful reconnect, the other Party should not send them
+				_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(message), react, "ignore")
+			case hubMessage:
+				// Mostly ping
+				err = l.handleOtherMessage(message)
+				// No default case necessary, because the protocol would return either a hubMessage or an error
 			}
+		case <-keepAlive.C:
+			// Send ping only when there was no write in the keepAliveInterval before
+			if sinceWrite := time.Since(l.hubConn.LastWriteStamp()); sinceWrite >= l.party.keepAliveInterval() {
+				_ = l.hubConn.Ping()
+				keepAlive.Reset(l.party.keepAliveInterval())
+			} else {
+				// Check again when the keepAliveInterval after the last write has elapsed
+				keepAlive.Reset(l.party.keepAliveInterval() - sinceWrite)
+			}
+		case <-timeout.C:
+			err = fmt.Errorf("client timeout interval elapsed (%v)", l.party.timeout())
+		case <-l.hubConn.Context().Done():
+			err = l.hubConn.Err()
 		}
-		if err != nil {
-			break msgLoop
-		}
+	}
+	// If the connection was aborted with an error, the other Party should know why
+	if abortErr := l.hubConn.Err(); abortErr != nil {
+		err = abortErr
 	}
 	l.party.onDisconnected(l.hubConn)
+	if causer, ok := l.conn.(closeCauser); ok {
+		if closedByOtherParty {
+			causer.setCloseCause(nil)
+		} else {
+			causer.setCloseCause(err)
+		}
+	}
 	_ = l.hubConn.Close(fmt.Sprintf("%v", err), l.party.allowReconnect())
+	// End the reader and writer of the hubConnection, also when the connection itself stays open
+	l.hubConn.Abort()
 	_ = l.dbg.Log(evt, "message loop ended")
 	l.invokeClient.cancelAllInvokes()
 }
 
-func (l *loop) receive() (message interface{}, err error) {
-	if message, err = l.hubConn.Receive(); err != nil {
-		_ = l.info.Log(evt, msgRecv, "error", err, msg, fmtMsg(message), react, "close connection")
+// resetTimer resets a timer which might have fired, but whose channel has not been drained
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
 	}
-	return message, err
+	timer.Reset(d)
 }
 
-func (l *loop) handleInvocationMessage(invocation invocationMessage) {
+func (l *loop) handleInvocationMessage(invocation invocationMessage) error {
 	_ = l.dbg.Log(evt, msgRecv, msg, fmtMsg(invocation))
-	// Transient hub, dispatch invocation here
-	if method, ok := getMethod(l.party.invocationTarget(l.hubConn), invocation.Target); !ok {
+	// Look up the method before creating the transient hub
+	hubMethod, ok := l.party.invocationMethod(invocation.Target)
+	// Aliases share the rate limit of the method. Unknown methods only count for the rate limit of all methods
+	limitedMethod := invocation.Target
+	if ok {
+		limitedMethod = hubMethod.name
+	}
+	if err := l.limiter.takeToken(limitedMethod, l.hubConn.Context().Done()); err != nil {
+		return l.rejectInvocation(invocation, err)
+	}
+	if !ok {
 		// Unable to find the method
-		_ = l.info.Log(evt, "getMethod", "error", "missing method", "name", invocation.Target, react, "send completion with error")
+		_ = l.info.Log(evt, "invocationMethod", "error", "missing method", "name", invocation.Target, react, "send completion with error")
 		_ = l.hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("Unknown method %s", invocation.Target))
-	} else if in, clientStreaming, err := buildMethodArguments(method, invocation, l.streamClient, l.protocol); err != nil {
+		return nil
+	}
+	var method reflect.Value
+	// Arguments which are not sent by the other Party
+	var in []reflect.Value
+	if hubMethod.handler.IsValid() {
+		method = hubMethod.handler
+		if hubMethod.withHubContext {
+			in = append(in, reflect.ValueOf(l.party.newConnectionHubContext(l.hubConn)))
+		}
+	} else {
+		// Transient hub, dispatch invocation here
+		method = hubMethod.value(l.party.invocationTarget(l.hubConn))
+	}
+	if args, clientStreaming, err := buildMethodArguments(hubMethod, invocation, l.streamClient, l.protocol); err != nil {
 		// argument build failed
 		_ = l.info.Log(evt, "buildMethodArguments", "error", err, "name", invocation.Target, react, "send completion with error")
 		_ = l.hubConn.Completion(invocation.InvocationID, nil, err.Error())
-	} else if clientStreaming {
+	} else if in = append(in, args...); clientStreaming {
 		// let the receiving method run independently
 		go func() {
 			defer l.recoverInvocationPanic(invocation)
@@ -141,20 +188,45 @@ func (l *loop) handleInvocationMessage(invocation invocationMessage) {
 	} else {
 		// Stream invocation is only allowed when the method has only one return value
 		// We allow no channel return values, because a client can receive as stream with only one item
-		if invocation.Type == 4 && method.Type().NumOut() != 1 {
+		if invocation.Type == 4 && hubMethod.numOut != 1 {
 			_ = l.hubConn.Completion(invocation.InvocationID, nil,
 				fmt.Sprintf("Stream invocation of method %s which has not return value kind channel", invocation.Target))
 		} else {
-			// hub method might take a long time
-			go func() {
+			release, err := l.limiter.acquire(l.hubConn.Context().Done())
+			if err != nil {
+				return l.rejectInvocation(invocation, err)
+			}
+			invoke := func() {
 				result := func() []reflect.Value {
+					defer release()
 					defer l.recoverInvocationPanic(invocation)
 					return method.Call(in)
 				}()
 				l.returnInvocationResult(invocation, result)
-			}()
+			}
+			if l.party.invocationOrder().isOrdered(hubMethod.name) {
+				l.ordered.run(invoke)
+			} else {
+				// hub method might take a long time
+				go invoke()
+			}
 		}
 	}
+	return nil
+}
+
+// rejectInvocation reacts to an invocation which exceeds an invocation limit as the policy of the limit defines.
+// It returns an error if the connection should be closed.
+func (l *loop) rejectInvocation(invocation invocationMessage, err *invocationLimitError) error {
+	if err.policy == LimitCloseConnection {
+		_ = l.info.Log(evt, "invocation limit", "error", err, "name", invocation.Target, react, "close connection")
+		return err
+	}
+	_ = l.info.Log(evt, "invocation limit", "error", err, "name", invocation.Target, react, "send completion with error")
+	if invocation.InvocationID != "" {
+		_ = l.hubConn.Completion(invocation.InvocationID, nil, err.Error())
+	}
+	return nil
 }
 
 func (l *loop) returnInvocationResult(invocation invocationMessage, result []reflect.Value) {
@@ -274,15 +346,14 @@ func (l *loop) recoverInvocationPanic(invocation invocationMessage) {
 	}
 }
 
-func buildMethodArguments(method reflect.Value, invocation invocationMessage,
+func buildMethodArguments(method *hubMethod, invocation invocationMessage,
 	streamClient *streamClient, protocol HubProtocol) (arguments []reflect.Value, clientStreaming bool, err error) {
-	if len(invocation.StreamIds)+len(invocation.Arguments) != method.Type().NumIn() {
+	if len(invocation.StreamIds)+len(invocation.Arguments) != len(method.in) {
 		return nil, false, fmt.Errorf("parameter mismatch calling method %v", invocation.Target)
 	}
-	arguments = make([]reflect.Value, method.Type().NumIn())
+	arguments = make([]reflect.Value, len(method.in))
 	chanCount := 0
-	for i := 0; i < method.Type().NumIn(); i++ {
-		t := method.Type().In(i)
+	for i, t := range method.in {
 		// Is it a channel for client streaming?
 		if arg, clientStreaming, err := streamClient.buildChannelArgument(invocation, t, chanCount); err != nil {
 			// it is, but channel count in invocation and method mismatch
@@ -306,19 +377,6 @@ func buildMethodArguments(method reflect.Value, invocation invocationMessage,
 	return arguments, chanCount > 0, nil
 }
 
-func getMethod(target interface{}, name string) (reflect.Value, bool) {
-	hubType := reflect.TypeOf(target)
-	hubValue := reflect.ValueOf(target)
-	name = strings.ToLower(name)
-	for i := 0; i < hubType.NumMethod(); i++ {
-		// Search in public methods
-		if m := hubType.Method(i); strings.ToLower(m.Name) == name {
-			return hubValue.Method(i), true
-		}
-	}
-	return reflect.Value{}, false
-}
-
 func fmtMsg(message interface{}) string {
 	return fmt.Sprintf("%#v", message)
 }
@@ -0,0 +1,163 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type netPipeConnection struct {
+	baseConnection
+	conn net.Conn
+}
+
+func (n *netPipeConnection) Read(p []byte) (int, error) {
+	return n.conn.Read(p)
+}
+
+func (n *netPipeConnection) Write(p []byte) (int, error) {
+	return n.conn.Write(p)
+}
+
+type loopBenchmarkHub struct {
+	Hub
+}
+
+func (l *loopBenchmarkHub) Done() {}
+
+// idleGoroutinesPerConnection is the number of goroutines of an idle connection:
+// the loop and the reader and writer of its hubConnection
+const idleGoroutinesPerConnection = 3
+
+// connectLoopClient serves a connection and returns the client end after the handshake
+func connectLoopClient(server Server, ctx context.Context) (net.Conn, error) {
+	cliConn, srvConn := net.Pipe()
+	go server.ServeConnection(&netPipeConnection{baseConnection{ctx: ctx, connectionID: "bench"}, srvConn})
+	if _, err := cliConn.Write([]byte("{\"protocol\":\"json\",\"version\":1}\u001e")); err != nil {
+		return nil, err
+	}
+	data := make([]byte, 1<<10)
+	if _, err := cliConn.Read(data); err != nil {
+		return nil, err
+	}
+	return cliConn, nil
+}
+
+func connectLoopBenchmarkClient(b *testing.B, server Server, ctx context.Context) net.Conn {
+	cliConn, err := connectLoopClient(server, ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return cliConn
+}
+
+var _ = Describe("Loop", func() {
+	Context("When connections are idle", func() {
+		It("should run no more than idleGoroutinesPerConnection goroutines per connection", func(done Done) {
+			const connections = 100
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			server, err := NewServer(ctx, SimpleHubFactory(&loopBenchmarkHub{}), Logger(log.NewNopLogger(), false))
+			Expect(err).NotTo(HaveOccurred())
+			goroutinesBefore := runtime.NumGoroutine()
+			conns := make([]net.Conn, connections)
+			for c := 0; c < connections; c++ {
+				conns[c], err = connectLoopClient(server, ctx)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			// Let the loops settle in their idle state
+			time.Sleep(100 * time.Millisecond)
+			// Goroutines of other specs which are still ending or starting change the count a little
+			goroutinesPerConn := func() float64 { return float64(runtime.NumGoroutine()-goroutinesBefore) / connections }
+			Consistently(goroutinesPerConn, 200*time.Millisecond).Should(BeNumerically("<", idleGoroutinesPerConnection+0.5))
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+			close(done)
+		}, 5.0)
+	})
+})
+
+func BenchmarkLoopReceive(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, err := NewServer(ctx, SimpleHubFactory(&loopBenchmarkHub{}), Logger(log.NewNopLogger(), false))
+	if err != nil {
+		b.Fatal(err)
+	}
+	cliConn := connectLoopBenchmarkClient(b, server, ctx)
+	// Drain everything the server sends, signal the completion of the Done() invocation
+	completed := make(chan struct{}, 1)
+	go func() {
+		var buf bytes.Buffer
+		data := make([]byte, 1<<10)
+		for {
+			n, err := cliConn.Read(data)
+			if err != nil {
+				return
+			}
+			buf.Write(data[:n])
+			if strings.Contains(buf.String(), `"invocationId":"done"`) {
+				buf.Reset()
+				completed <- struct{}{}
+			}
+		}
+	}()
+	ping := []byte("{\"type\":6}\u001e")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cliConn.Write(ping); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := cliConn.Write([]byte("{\"type\":1,\"invocationId\":\"done\",\"target\":\"done\"}\u001e")); err != nil {
+		b.Fatal(err)
+	}
+	<-completed
+}
+
+func BenchmarkLoopIdleConnections(b *testing.B) {
+	const connections = 1000
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		server, err := NewServer(ctx, SimpleHubFactory(&loopBenchmarkHub{}), Logger(log.NewNopLogger(), false))
+		if err != nil {
+			b.Fatal(err)
+		}
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		goroutinesBefore := runtime.NumGoroutine()
+		conns := make([]net.Conn, connections)
+		for c := 0; c < connections; c++ {
+			conns[c] = connectLoopBenchmarkClient(b, server, ctx)
+		}
+		// Let the loops settle in their idle state
+		time.Sleep(100 * time.Millisecond)
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		goroutinesPerConn := float64(runtime.NumGoroutine()-goroutinesBefore) / connections
+		if goroutinesPerConn > idleGoroutinesPerConnection {
+			b.Fatalf("%v goroutines per idle connection, expected no more than %v", goroutinesPerConn, idleGoroutinesPerConnection)
+		}
+		b.ReportMetric(goroutinesPerConn, "goroutines/conn")
+		b.ReportMetric(float64(int64(after.HeapAlloc+after.StackInuse)-int64(before.HeapAlloc+before.StackInuse))/connections, "bytes/conn")
+		runtime.KeepAlive(conns)
+		cancel()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		// Wait for the connections to be torn down before the next measurement
+		for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutinesBefore && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
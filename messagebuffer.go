@@ -0,0 +1,161 @@
+package signalr
+
+import (
+	"context"
+	"fmt"
+	"github.com/rotisserie/eris"
+	"sync"
+)
+
+// messageBuffer keeps the sequenced messages sent over a connection with stateful reconnect until the other
+// Party acknowledges them. It also counts the received sequenced messages, so messages which are resent after a
+// reconnect, but were already received, are skipped.
+type messageBuffer struct {
+	mx    sync.Mutex
+	limit uint
+	size  uint
+	sent  []sequencedMessage
+	// nextID is the sequence id of the next sent message
+	nextID uint64
+	// space receives a value when an ack has removed messages
+	space chan struct{}
+	// receivedID is the sequence id of the last received message
+	receivedID uint64
+	// latestID is the highest sequence id received
+	latestID uint64
+	// ackedID is the highest sequence id acknowledged to the other Party
+	ackedID uint64
+	// awaitSequence is set when the transport was lost. Until the other Party sends its sequence message,
+	// received messages can not be counted.
+	awaitSequence bool
+}
+
+type sequencedMessage struct {
+	id   uint64
+	data []byte
+}
+
+func newMessageBuffer(limit uint) *messageBuffer {
+	return &messageBuffer{
+		limit:  limit,
+		nextID: 1,
+		space:  make(chan struct{}, 1),
+	}
+}
+
+// waitForSpace blocks until a message of size fits into the buffer. A message larger than the limit fits into an
+// empty buffer. There must be only one waiting sender.
+func (b *messageBuffer) waitForSpace(ctx context.Context, size uint) error {
+	for {
+		b.mx.Lock()
+		fits := len(b.sent) == 0 || b.size+size <= b.limit
+		b.mx.Unlock()
+		if fits {
+			return nil
+		}
+		select {
+		case <-b.space:
+		case <-ctx.Done():
+			return eris.Wrap(ctx.Err(), "waiting for acknowledgement of sent messages canceled")
+		}
+	}
+}
+
+// add appends a sent message
+func (b *messageBuffer) add(data []byte) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.sent = append(b.sent, sequencedMessage{id: b.nextID, data: data})
+	b.nextID++
+	b.size += uint(len(data))
+}
+
+// ack removes the messages up to the sequence id
+func (b *messageBuffer) ack(id uint64) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	i := 0
+	for ; i < len(b.sent) && b.sent[i].id <= id; i++ {
+		b.size -= uint(len(b.sent[i].data))
+	}
+	b.sent = b.sent[i:]
+	select {
+	case b.space <- struct{}{}:
+	default:
+	}
+}
+
+// resend returns the sequence id of the first message which has to be resent and the messages
+func (b *messageBuffer) resend() (uint64, [][]byte) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	messages := make([][]byte, len(b.sent))
+	for i, m := range b.sent {
+		messages[i] = m.data
+	}
+	if len(b.sent) > 0 {
+		return b.sent[0].id, messages
+	}
+	return b.nextID, messages
+}
+
+// since returns the messages from the sequence id on and the sequence id of the next message
+func (b *messageBuffer) since(id uint64) ([][]byte, uint64) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	var messages [][]byte
+	for _, m := range b.sent {
+		if m.id >= id {
+			messages = append(messages, m.data)
+		}
+	}
+	return messages, b.nextID
+}
+
+// disconnected is called when the transport is lost
+func (b *messageBuffer) disconnected() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.awaitSequence = true
+}
+
+// sequence handles a sequence message, which tells the sequence id of the next received message
+func (b *messageBuffer) sequence(id uint64) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if id == 0 || id > b.latestID+1 {
+		return fmt.Errorf("sequence id %v does not follow the last received sequence id %v", id, b.latestID)
+	}
+	b.receivedID = id - 1
+	b.awaitSequence = false
+	// The last ack might have been lost with the transport
+	b.ackedID = 0
+	return nil
+}
+
+// receive counts a received sequenced message. It returns false if the message has been received before
+// or can not be counted, because the sequence message after a reconnect is missing.
+func (b *messageBuffer) receive() bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.awaitSequence {
+		return false
+	}
+	b.receivedID++
+	if b.receivedID <= b.latestID {
+		return false
+	}
+	b.latestID = b.receivedID
+	return true
+}
+
+// pendingAck returns the sequence id which should be acknowledged, if there are unacknowledged received messages
+func (b *messageBuffer) pendingAck() (uint64, bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.latestID <= b.ackedID {
+		return 0, false
+	}
+	b.ackedID = b.latestID
+	return b.latestID, true
+}
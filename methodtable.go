@@ -0,0 +1,185 @@
+package signalr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// hubMethod describes a method which can be invoked by the other Party
+type hubMethod struct {
+	name  string
+	index int
+	// handler is set when the method is a function registered with Server.Handle instead of a method of the hub
+	handler reflect.Value
+	// withHubContext is true if handler expects the HubContext as first parameter. The HubContext is not part of in
+	withHubContext bool
+	// in holds the parameter types
+	in []reflect.Type
+	// uploadStreams is the number of channel parameters, which are fed by client streaming
+	uploadStreams int
+	numOut        int
+	// returnsChan is true if the method returns exactly one value of kind channel
+	returnsChan bool
+}
+
+// value returns the method bound to target. target must be of the type the methodTable was built for
+func (m *hubMethod) value(target interface{}) reflect.Value {
+	return reflect.ValueOf(target).Method(m.index)
+}
+
+// methodTable maps the lower case names of the methods of a hub or client receiver type to their description.
+// SignalR method names are case insensitive. Methods can be added and removed while connections are served.
+type methodTable struct {
+	mx      sync.RWMutex
+	methods map[string]*hubMethod
+}
+
+var hubContextType = reflect.TypeOf((*HubContext)(nil)).Elem()
+
+// hubBaseMethods are the methods of Hub. The lifecycle methods are called by the server,
+// the others are helpers for the hub implementation. None of them can be invoked by clients.
+var hubBaseMethods = func() []string {
+	t := reflect.TypeOf(&Hub{})
+	names := make([]string, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		names[i] = t.Method(i).Name
+	}
+	return names
+}()
+
+// newMethodTable builds the methodTable for all exported methods of receiverType,
+// except the excluded ones. It fails when two method names only differ by case
+// or when a method signature can not be used for invocations.
+func newMethodTable(receiverType reflect.Type, excluded ...string) (*methodTable, error) {
+	table := &methodTable{methods: make(map[string]*hubMethod)}
+	if receiverType == nil {
+		return table, nil
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, name := range excluded {
+		skip[strings.ToLower(name)] = true
+	}
+	for i := 0; i < receiverType.NumMethod(); i++ {
+		m := receiverType.Method(i)
+		if skip[strings.ToLower(m.Name)] {
+			continue
+		}
+		key := strings.ToLower(m.Name)
+		if other, ok := table.methods[key]; ok {
+			return nil, fmt.Errorf("%v: method names %v and %v are ambiguous, method names are case insensitive", receiverType, other.name, m.Name)
+		}
+		// m.Type includes the receiver as first parameter
+		method, err := newHubMethod(m.Name, m.Type, 1)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", receiverType, err)
+		}
+		method.index = m.Index
+		table.methods[key] = method
+	}
+	return table, nil
+}
+
+// newHandlerMethod describes the function handler as method with the given name
+func newHandlerMethod(name string, handler interface{}) (*hubMethod, error) {
+	t := reflect.TypeOf(handler)
+	if t == nil || t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler for method %v is not a func but %T", name, handler)
+	}
+	firstParam := 0
+	if t.NumIn() > 0 && t.In(0) == hubContextType {
+		firstParam = 1
+	}
+	method, err := newHubMethod(name, t, firstParam)
+	if err != nil {
+		return nil, err
+	}
+	method.handler = reflect.ValueOf(handler)
+	method.withHubContext = firstParam == 1
+	return method, nil
+}
+
+// newHubMethod describes a func of type t. Parameters before firstParam are not sent by the other Party
+func newHubMethod(name string, t reflect.Type, firstParam int) (*hubMethod, error) {
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("method %v is variadic", name)
+	}
+	method := &hubMethod{
+		name:   name,
+		in:     make([]reflect.Type, t.NumIn()-firstParam),
+		numOut: t.NumOut(),
+	}
+	for i := firstParam; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if !isSupportedType(in) {
+			return nil, fmt.Errorf("method %v has parameter %v of unsupported type %v", name, i-firstParam, in)
+		}
+		if in.Kind() == reflect.Chan {
+			method.uploadStreams++
+		}
+		method.in[i-firstParam] = in
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		out := t.Out(i)
+		if !isSupportedType(out) {
+			return nil, fmt.Errorf("method %v has return value %v of unsupported type %v", name, i, out)
+		}
+		if out.Kind() == reflect.Chan {
+			if t.NumOut() != 1 {
+				return nil, fmt.Errorf("method %v returns a channel together with other values", name)
+			}
+			method.returnsChan = true
+		}
+	}
+	return method, nil
+}
+
+// isSupportedType checks if values of type t can be transferred. Channels must be readable by the receiving side
+func isSupportedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func, reflect.UnsafePointer:
+		return false
+	case reflect.Chan:
+		return t.ChanDir() != reflect.SendDir
+	default:
+		return true
+	}
+}
+
+// method looks up a method by its case insensitive name
+func (t *methodTable) method(name string) (*hubMethod, bool) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	m, ok := t.methods[strings.ToLower(name)]
+	return m, ok
+}
+
+// set adds the method under name or replaces the method with this name
+func (t *methodTable) set(name string, method *hubMethod) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.methods[strings.ToLower(name)] = method
+}
+
+// alias makes the method with the name method also available as alias
+func (t *methodTable) alias(alias string, method string) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	m, ok := t.methods[strings.ToLower(method)]
+	if !ok {
+		return fmt.Errorf("can not alias unknown method %v", method)
+	}
+	if _, ok := t.methods[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("alias %v for method %v is ambiguous, a method with this name exists", alias, method)
+	}
+	t.methods[strings.ToLower(alias)] = m
+	return nil
+}
+
+// remove removes the method with the name. It is not an error if no method with this name exists
+func (t *methodTable) remove(name string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.methods, strings.ToLower(name))
+}
@@ -0,0 +1,203 @@
+package signalr
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"reflect"
+	"sync/atomic"
+)
+
+type ambiguousHub struct {
+	Hub
+}
+
+func (a *ambiguousHub) Method() {}
+
+func (a *ambiguousHub) METHOD() {}
+
+type variadicHub struct {
+	Hub
+}
+
+func (v *variadicHub) Sum(values ...int) {}
+
+type funcParamHub struct {
+	Hub
+}
+
+func (f *funcParamHub) Call(fn func()) {}
+
+type sendOnlyChanHub struct {
+	Hub
+}
+
+func (s *sendOnlyChanHub) Upload(upload chan<- int) {}
+
+type chanAndErrorHub struct {
+	Hub
+}
+
+func (c *chanAndErrorHub) Stream() (<-chan int, error) {
+	return nil, nil
+}
+
+var methodTableHubCount int32
+
+type countingHub struct {
+	Hub
+}
+
+func (c *countingHub) Ping() string {
+	return "pong"
+}
+
+var _ = Describe("Method table", func() {
+	Context("When the hub has methods which only differ by case", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&ambiguousHub{}))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ambiguous"))
+		})
+	})
+	Context("When the hub has methods with unsupported signatures", func() {
+		It("NewServer should fail", func() {
+			for _, hub := range []HubInterface{&variadicHub{}, &funcParamHub{}, &sendOnlyChanHub{}, &chanAndErrorHub{}} {
+				_, err := NewServer(context.TODO(), SimpleHubFactory(hub))
+				Expect(err).To(HaveOccurred(), "%T", hub)
+			}
+		})
+	})
+	Context("When the methods with unsupported signatures are hidden", func() {
+		It("NewServer should succeed", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&funcParamHub{}), HideHubMethods("call"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&funcParamHub{}), HideHubMethods("Call"),
+				HubMethodAlias("invoke", "Call"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When a client receiver has methods with unsupported signatures", func() {
+		It("Start should fail", func() {
+			client, err := NewClient(context.TODO(), &pipeConnection{})
+			Expect(err).NotTo(HaveOccurred())
+			client.SetReceiver(&funcParamHub{})
+			Expect(client.Start()).To(HaveOccurred())
+		})
+	})
+	Context("When the hub is valid", func() {
+		It("should contain all exported methods but the methods of Hub", func() {
+			table, err := newMethodTable(reflect.TypeOf(&invocationHub{}), hubBaseMethods...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(table.methods).To(HaveKey("simpleint"))
+			Expect(table.methods).To(HaveKey("async"))
+			for _, name := range []string{"initialize", "onconnected", "ondisconnected", "clients", "groups", "items", "logger"} {
+				Expect(table.methods).NotTo(HaveKey(name))
+			}
+			m, ok := table.method("SimpleString")
+			Expect(ok).To(BeTrue())
+			Expect(m.in).To(HaveLen(2))
+			Expect(m.numOut).To(Equal(1))
+			m, ok = table.method("ASYNC")
+			Expect(ok).To(BeTrue())
+			Expect(m.returnsChan).To(BeTrue())
+		})
+	})
+	Context("When an unknown method is invoked", func() {
+		It("should not create a hub instance", func(done Done) {
+			server, err := NewServer(context.TODO(),
+				HubFactory(func() HubInterface {
+					atomic.AddInt32(&methodTableHubCount, 1)
+					return &countingHub{}
+				}),
+				Logger(log.NewLogfmtLogger(os.Stderr), false))
+			Expect(err).NotTo(HaveOccurred())
+			conn := newTestingConnectionForServer()
+			go server.ServeConnection(conn)
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"ping"}`)
+			Expect(receiveCompletion(conn).Result).To(Equal("pong"))
+			created := atomic.LoadInt32(&methodTableHubCount)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"unknown"}`)
+			Expect(receiveCompletion(conn).Error).To(Equal("Unknown method unknown"))
+			Expect(atomic.LoadInt32(&methodTableHubCount)).To(Equal(created))
+			close(done)
+		}, 2.0)
+	})
+})
+
+func connectRegistration(options ...func(Party) error) (Server, *testingConnection) {
+	server, err := NewServer(context.TODO(), append([]func(Party) error{SimpleHubFactory(&invocationHub{}),
+		Logger(log.NewLogfmtLogger(os.Stderr), false)}, options...)...)
+	Expect(err).NotTo(HaveOccurred())
+	conn := newTestingConnectionForServer()
+	go server.ServeConnection(conn)
+	return server, conn
+}
+
+var _ = Describe("Hub method registration", func() {
+	Context("When a method of the embedded Hub is invoked", func() {
+		It("should return an error", func(done Done) {
+			_, conn := connectRegistration()
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"items"}`)
+			Expect(receiveCompletion(conn).Error).To(Equal("Unknown method items"))
+			close(done)
+		}, 2.0)
+	})
+	Context("When a method is renamed with HubMethodAlias and HideHubMethods", func() {
+		It("should be invoked by the new name only", func(done Done) {
+			_, conn := connectRegistration(HubMethodAlias("increment", "SimpleInt"), HideHubMethods("SimpleInt"))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"increment","arguments":[1]}`)
+			Expect(receiveCompletion(conn).Result).To(Equal(2.0))
+			Expect(<-invocationQueue).To(Equal("SimpleInt(1)"))
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"simpleint","arguments":[1]}`)
+			Expect(receiveCompletion(conn).Error).To(Equal("Unknown method simpleint"))
+			close(done)
+		}, 2.0)
+	})
+	Context("When an alias collides with a method", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&invocationHub{}), HubMethodAlias("simple", "SimpleInt"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When an alias refers to an unknown method", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&invocationHub{}), HubMethodAlias("alias", "Unknown"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When a handler is registered with Handle", func() {
+		It("should be invoked with the HubContext of the connection", func(done Done) {
+			server, conn := connectRegistration()
+			Expect(server.Handle("sendMessage", func(ctx HubContext, message string) string {
+				return ctx.ConnectionID() + ":" + message
+			})).To(Succeed())
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"sendMessage","arguments":["hello"]}`)
+			Expect(receiveCompletion(conn).Result).To(Equal(conn.ConnectionID() + ":hello"))
+			close(done)
+		}, 2.0)
+		It("should be invoked without HubContext", func(done Done) {
+			server, conn := connectRegistration()
+			Expect(server.Handle("add", func(a, b int) int { return a + b })).To(Succeed())
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"ADD","arguments":[1,2]}`)
+			Expect(receiveCompletion(conn).Result).To(Equal(3.0))
+			close(done)
+		}, 2.0)
+		It("should not be invoked after it was removed", func(done Done) {
+			server, conn := connectRegistration()
+			Expect(server.Handle("add", func(a, b int) int { return a + b })).To(Succeed())
+			server.RemoveHandler("add")
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"add","arguments":[1,2]}`)
+			Expect(receiveCompletion(conn).Error).To(Equal("Unknown method add"))
+			close(done)
+		}, 2.0)
+		It("should fail when the handler is no func or has an unsupported signature", func() {
+			server, err := NewServer(context.TODO(), SimpleHubFactory(&invocationHub{}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.Handle("noFunc", 1)).NotTo(Succeed())
+			Expect(server.Handle("variadic", func(a ...int) {})).NotTo(Succeed())
+		})
+	})
+})
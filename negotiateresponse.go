@@ -6,8 +6,11 @@ type availableTransport struct {
 }
 
 type negotiateResponse struct {
-	ConnectionID        string               `json:"connectionId"`
-	AvailableTransports []availableTransport `json:"availableTransports"`
+	NegotiateVersion     int                  `json:"negotiateVersion,omitempty"`
+	ConnectionID         string               `json:"connectionId"`
+	ConnectionToken      string               `json:"connectionToken,omitempty"`
+	AvailableTransports  []availableTransport `json:"availableTransports"`
+	UseStatefulReconnect bool                 `json:"useStatefulReconnect,omitempty"`
 }
 
 func (nr *negotiateResponse) getTransferFormats(transportType string) []string {
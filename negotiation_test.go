@@ -0,0 +1,122 @@
+package signalr
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// testGauge is a metrics.Gauge which keeps the last value for all labels together
+type testGauge struct {
+	mx    sync.Mutex
+	value float64
+}
+
+func (t *testGauge) With(...string) metrics.Gauge {
+	return t
+}
+
+func (t *testGauge) Set(value float64) {
+	t.mx.Lock()
+	t.value = value
+	t.mx.Unlock()
+}
+
+func (t *testGauge) Add(delta float64) {
+	t.mx.Lock()
+	t.value += delta
+	t.mx.Unlock()
+}
+
+func (t *testGauge) Value() float64 {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.value
+}
+
+func negotiateStatus(serverURL string, query string) int {
+	resp, err := http.Post(serverURL+"/negotiate"+query, "text/plain;charset=UTF-8", nil)
+	Expect(err).NotTo(HaveOccurred())
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+var _ = Describe("Negotiation", func() {
+	var httpServer *httptest.Server
+	var gauge *testGauge
+	var cancel context.CancelFunc
+	startServer := func(options ...func(Party) error) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		gauge = &testGauge{}
+		server, err := NewServer(ctx, append([]func(Party) error{
+			SimpleHubFactory(&addHub{}),
+			NegotiatedConnectionsGauge(gauge),
+			Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+		Expect(err).NotTo(HaveOccurred())
+		httpServer = httptest.NewServer(server.Handler())
+	}
+	AfterEach(func() {
+		cancel()
+		httpServer.Close()
+	})
+	Context("When a negotiated connection is not connected in time", func() {
+		It("should be removed", func(done Done) {
+			startServer(NegotiateTimeout(time.Second))
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			Expect(gauge.Value()).To(Equal(1.0))
+			Eventually(gauge.Value, 3.0).Should(Equal(0.0))
+			req, err := http.NewRequest("GET", httpServer.URL+"?id="+url.QueryEscape(nr.ConnectionToken), nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Accept", "text/event-stream")
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(404))
+			close(done)
+		}, 5.0)
+	})
+	Context("When a client has too many pending negotiations", func() {
+		It("should reject further negotiations until the pending ones expire", func(done Done) {
+			startServer(MaxPendingNegotiations(2), NegotiateTimeout(time.Second))
+			Expect(negotiateStatus(httpServer.URL, "?negotiateVersion=1")).To(Equal(200))
+			Expect(negotiateStatus(httpServer.URL, "?negotiateVersion=1")).To(Equal(200))
+			Expect(negotiateStatus(httpServer.URL, "?negotiateVersion=1")).To(Equal(429))
+			Eventually(gauge.Value, 3.0).Should(Equal(0.0))
+			Expect(negotiateStatus(httpServer.URL, "?negotiateVersion=1")).To(Equal(200))
+			close(done)
+		}, 5.0)
+	})
+	Context("When a connected connection ends", func() {
+		It("should be removed", func(done Done) {
+			startServer(HTTPTransports("WebSockets"), NegotiateTimeout(500*time.Millisecond))
+			client, err := NewHTTPClient(context.TODO(), httpServer.URL,
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Start()).To(Succeed())
+			result := <-client.Invoke("Add2", 1)
+			Expect(result.Error).NotTo(HaveOccurred())
+			// Connected connections do not expire
+			Consistently(gauge.Value, 0.7).Should(Equal(1.0))
+			Expect(client.Stop()).To(Succeed())
+			Eventually(gauge.Value, 3.0).Should(Equal(0.0))
+			close(done)
+		}, 5.0)
+	})
+	Context("When the options are invalid", func() {
+		It("NewServer should fail", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), NegotiateTimeout(0))
+			Expect(err).To(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), MaxPendingNegotiations(-1))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,12 @@
+package signalr
+
+import (
+	"context"
+	"net"
+)
+
+// NewNetClient creates a signalR Client which connects over conn, e.g. a TCP, TLS or Unix domain socket connection
+// created with net.Dial or tls.Dial. conn is closed when ctx is done.
+func NewNetClient(ctx context.Context, conn net.Conn, options ...func(Party) error) (Client, error) {
+	return NewClient(ctx, newNetConnection(ctx, newConnectionID(), conn), options...)
+}
@@ -0,0 +1,53 @@
+package signalr
+
+import (
+	"context"
+	"github.com/rotisserie/eris"
+	"net"
+	"time"
+)
+
+// netConnection is a Connection over a net.Conn, e.g. a TCP, TLS or Unix domain socket connection
+type netConnection struct {
+	baseConnection
+	conn net.Conn
+}
+
+// newNetConnection creates a netConnection. conn is closed when ctx is done.
+func newNetConnection(ctx context.Context, connectionID string, conn net.Conn) *netConnection {
+	n := &netConnection{
+		baseConnection: baseConnection{
+			ctx:             ctx,
+			connectionID:    connectionID,
+			requestFeatures: NewRequestFeaturesFromConn(conn),
+		},
+		conn: conn,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	return n
+}
+
+func (n *netConnection) Write(p []byte) (int, error) {
+	if err := n.Context().Err(); err != nil {
+		return 0, eris.Wrap(err, "netConnection canceled")
+	}
+	if n.timeout > 0 {
+		defer func() { _ = n.conn.SetWriteDeadline(time.Time{}) }()
+		_ = n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+	}
+	return n.conn.Write(p)
+}
+
+func (n *netConnection) Read(p []byte) (int, error) {
+	if err := n.Context().Err(); err != nil {
+		return 0, eris.Wrap(err, "netConnection canceled")
+	}
+	if n.timeout > 0 {
+		defer func() { _ = n.conn.SetReadDeadline(time.Time{}) }()
+		_ = n.conn.SetReadDeadline(time.Now().Add(n.timeout))
+	}
+	return n.conn.Read(p)
+}
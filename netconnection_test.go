@@ -0,0 +1,161 @@
+package signalr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// newTestCertificate creates a self signed certificate for 127.0.0.1
+func newTestCertificate() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"signalr test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// failingListener fails the first Accept calls before it accepts from listener
+type failingListener struct {
+	net.Listener
+	failures chan error
+}
+
+func (f *failingListener) Accept() (net.Conn, error) {
+	select {
+	case err := <-f.failures:
+		return nil, err
+	default:
+		return f.Listener.Accept()
+	}
+}
+
+var _ = Describe("Net connections", func() {
+	// serve returns locals, so the ServeListener of a previous spec which is still ending does not see the next ones
+	serve := func(listener net.Listener) (context.CancelFunc, chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		server, err := NewServer(ctx, SimpleHubFactory(&addHub{}),
+			Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.Handle("remoteAddr", func(ctx HubContext) string {
+			return ctx.RequestFeatures().RemoteAddr()
+		})).To(Succeed())
+		Expect(server.Handle("tls", func(ctx HubContext) bool {
+			return ctx.RequestFeatures().TLS() != nil
+		})).To(Succeed())
+		served := make(chan error, 1)
+		go func() { served <- server.ServeListener(listener) }()
+		return cancel, served
+	}
+	invokeOverNet := func(conn net.Conn, method string, arguments ...interface{}) interface{} {
+		client, err := NewNetClient(context.TODO(), conn,
+			Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Start()).To(Succeed())
+		defer func() { _ = client.Stop() }()
+		result := <-client.Invoke(method, arguments...)
+		Expect(result.Error).NotTo(HaveOccurred())
+		return result.Value
+	}
+	Context("When the server listens on TCP", func() {
+		It("should serve clients and end when the server context is done", func(done Done) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			cancel, served := serve(listener)
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invokeOverNet(conn, "Add2", 1)).To(Equal(json.Number("3")))
+			conn, err = net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invokeOverNet(conn, "remoteAddr")).To(Equal(conn.LocalAddr().String()))
+			cancel()
+			Expect(<-served).NotTo(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When the server listens with TLS", func() {
+		It("should serve clients and know the TLS state", func(done Done) {
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{newTestCertificate()}})
+			Expect(err).NotTo(HaveOccurred())
+			cancel, served := serve(listener)
+			conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invokeOverNet(conn, "tls")).To(BeTrue())
+			cancel()
+			Expect(<-served).NotTo(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When the server listens on a Unix domain socket", func() {
+		It("should serve clients", func(done Done) {
+			if runtime.GOOS == "windows" {
+				Skip("no Unix domain sockets")
+			}
+			dir, err := ioutil.TempDir("", "signalr")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = os.RemoveAll(dir) }()
+			listener, err := net.Listen("unix", filepath.Join(dir, "hub.sock"))
+			Expect(err).NotTo(HaveOccurred())
+			cancel, served := serve(listener)
+			conn, err := net.Dial("unix", filepath.Join(dir, "hub.sock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invokeOverNet(conn, "Add2", 2)).To(Equal(json.Number("4")))
+			cancel()
+			Expect(<-served).NotTo(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When Accept fails", func() {
+		It("should retry and serve clients", func(done Done) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			failing := &failingListener{Listener: listener, failures: make(chan error, 3)}
+			for i := 0; i < 3; i++ {
+				failing.failures <- errors.New("too many open files")
+			}
+			cancel, served := serve(failing)
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invokeOverNet(conn, "Add2", 1)).To(Equal(json.Number("3")))
+			cancel()
+			Expect(<-served).NotTo(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When the listener is closed while the server context is not done", func() {
+		It("should return the error of Accept", func(done Done) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			cancel, served := serve(listener)
+			defer cancel()
+			Expect(listener.Close()).To(Succeed())
+			Expect(<-served).To(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+})
@@ -2,8 +2,11 @@ package signalr
 
 import (
 	"errors"
+	"fmt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"strings"
 	"time"
 )
 
@@ -56,7 +59,8 @@ func StreamBufferCapacity(capacity uint) func(Party) error {
 }
 
 // MaximumReceiveMessageSize is the maximum size of a single incoming hub message.
-// Default is 32KB
+// The size includes the framing of the protocol, e.g. the record separator of JSON messages
+// or the length prefix of binary messages. Default is 32KB
 func MaximumReceiveMessageSize(size uint) func(Party) error {
 	return func(p Party) error {
 		if size == 0 {
@@ -87,6 +91,243 @@ func EnableDetailedErrors(enable bool) func(Party) error {
 	}
 }
 
+// OutboundQueueCapacity is the maximum number of messages which can be queued for sending on one connection.
+// Messages are written to the connection by one writer per connection. If the other Party is slow and the queue
+// is full, the OutboundOverflowPolicy is applied.
+// Default is 256.
+func OutboundQueueCapacity(capacity uint) func(Party) error {
+	return func(p Party) error {
+		if capacity == 0 {
+			return errors.New("unsupported OutboundQueueCapacity 0")
+		}
+		p.setOutboundQueueCapacity(capacity)
+		return nil
+	}
+}
+
+// OutboundOverflowPolicy sets what happens when a message should be sent, but the outbound queue of the connection is full.
+// Note that with OverflowBlock, sending to all clients or a group is blocked by a slow client up to the OutboundBlockTimeout.
+// Default is OverflowBlock.
+func OutboundOverflowPolicy(policy OverflowPolicy) func(Party) error {
+	return func(p Party) error {
+		switch policy {
+		case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+			p.setOutboundOverflowPolicy(policy)
+			return nil
+		default:
+			return fmt.Errorf("unsupported OverflowPolicy: %v", policy)
+		}
+	}
+}
+
+// OutboundBlockTimeout is the maximum time a sender is blocked when the outbound queue is full
+// and the OutboundOverflowPolicy is OverflowBlock. It is also the maximum time the close message of a connection
+// waits for a pending write.
+// Default is 5 seconds.
+func OutboundBlockTimeout(timeout time.Duration) func(Party) error {
+	return func(p Party) error {
+		p.setOutboundBlockTimeout(timeout)
+		return nil
+	}
+}
+
+// OutboundMessageTTL is the maximum time a message waits in the outbound queue.
+// Messages which are older when they reach the head of the queue are dropped.
+// Default is 0, which means messages never expire.
+func OutboundMessageTTL(ttl time.Duration) func(Party) error {
+	return func(p Party) error {
+		p.setOutboundMessageTTL(ttl)
+		return nil
+	}
+}
+
+// OutboundDropCounter sets a counter which is incremented for each message dropped from an outbound queue.
+// The counter is labeled with "reason", which is one of "timeout", "oldest", "newest", "ttl" or "disconnect".
+// See github.com/go-kit/kit/metrics for adapters to common metrics systems.
+func OutboundDropCounter(counter metrics.Counter) func(Party) error {
+	return func(p Party) error {
+		p.setOutboundDropCounter(counter)
+		return nil
+	}
+}
+
+// MaximumParallelInvocationsPerClient is the maximum number of hub method invocations from one connection which
+// are executed in parallel. policy defines what happens with further invocations. Invocations with client streams
+// are not counted, because they need the connection to receive their stream items.
+// Default is 0, which means no limit.
+func MaximumParallelInvocationsPerClient(max uint, policy LimitPolicy) func(Party) error {
+	return func(p Party) error {
+		if err := validLimitPolicy(policy); err != nil {
+			return err
+		}
+		p.invocationLimits().maxParallel = max
+		p.invocationLimits().parallelPolicy = policy
+		return nil
+	}
+}
+
+// InvocationRateLimit limits the rate of hub method invocations from one connection with a token bucket
+// which holds up to burst tokens and is refilled with perSecond tokens per second.
+// policy defines what happens with invocations when the bucket is empty.
+// Default is no limit.
+func InvocationRateLimit(perSecond float64, burst uint, policy LimitPolicy) func(Party) error {
+	return func(p Party) error {
+		limit, err := newRateLimit(perSecond, burst, policy)
+		if err != nil {
+			return err
+		}
+		p.invocationLimits().rate = limit
+		return nil
+	}
+}
+
+// MethodInvocationRateLimit limits the rate of invocations of the hub method with the given name from one connection,
+// like InvocationRateLimit does for all invocations. Invocations by an alias of the method count for its limit.
+// Default is no limit.
+func MethodInvocationRateLimit(method string, perSecond float64, burst uint, policy LimitPolicy) func(Party) error {
+	return func(p Party) error {
+		limit, err := newRateLimit(perSecond, burst, policy)
+		if err != nil {
+			return err
+		}
+		if p.invocationLimits().methodRates == nil {
+			p.invocationLimits().methodRates = make(map[string]*rateLimit)
+		}
+		p.invocationLimits().methodRates[strings.ToLower(method)] = limit
+		return nil
+	}
+}
+
+// OrderedInvocations sets if the invocations from one connection are executed one after another, in the order
+// they were received, or in parallel. Streaming results are sent independently of the order, but the hub method
+// which returns the stream is executed in order. Invocations with client streams are always executed independently.
+// Use InvocationOrder to exclude long-running methods, which would delay all further invocations.
+// Default is false.
+func OrderedInvocations(ordered bool) func(Party) error {
+	return func(p Party) error {
+		p.invocationOrder().ordered = ordered
+		return nil
+	}
+}
+
+// InvocationOrder overrides OrderedInvocations for the hub method with the given name.
+func InvocationOrder(method string, ordered bool) func(Party) error {
+	return func(p Party) error {
+		if p.invocationOrder().methods == nil {
+			p.invocationOrder().methods = make(map[string]bool)
+		}
+		p.invocationOrder().methods[strings.ToLower(method)] = ordered
+		return nil
+	}
+}
+
+// WebSocketCompression enables the permessage-deflate extension for WebSocket connections.
+// The server accepts compression when the client offers it, NewHTTPClient offers it to the server.
+// With contextTakeover, the compression state is kept between messages, which compresses repetitive messages
+// better, but needs additional memory for each connection. Without it, each message is compressed on its own.
+// The compression level can not be set: nhooyr.io/websocket compresses each message with the stateless deflate
+// of github.com/klauspost/compress, which has no compression level.
+// Default is no compression.
+func WebSocketCompression(contextTakeover bool) func(Party) error {
+	return func(p Party) error {
+		p.webSocketCompression().enabled = true
+		p.webSocketCompression().contextTakeover = contextTakeover
+		return nil
+	}
+}
+
+// WebSocketCompressionThreshold sets the minimum size of a message in bytes which is compressed, when
+// WebSocketCompression is enabled. Smaller messages are sent uncompressed.
+// 0 means the default of 512 bytes without context takeover and 128 bytes with context takeover.
+func WebSocketCompressionThreshold(size int) func(Party) error {
+	return func(p Party) error {
+		if size < 0 {
+			return errors.New("webSocketCompressionThreshold must not be negative")
+		}
+		p.webSocketCompression().threshold = size
+		return nil
+	}
+}
+
+// StatefulReconnect enables stateful reconnect for WebSocket connections. When the transport of a connection
+// is lost, the client reconnects with a new transport to the same connection. Sent messages are kept until the
+// other Party acknowledges them, and the messages which got lost with the transport are resent.
+// The server allows stateful reconnect to clients which ask for it, NewHTTPClient asks the server for it.
+// The connection must use protocol version 2, which the client requests in the handshake when the server allowed it.
+// bufferSize is the maximum size in bytes of the sent, but not acknowledged messages. When it is reached,
+// sending waits for acknowledgements. timeout is the time in which a lost transport must be replaced.
+// Default is no stateful reconnect.
+func StatefulReconnect(bufferSize uint, timeout time.Duration) func(Party) error {
+	return func(p Party) error {
+		if bufferSize == 0 {
+			return errors.New("statefulReconnect bufferSize must be greater than 0")
+		}
+		if timeout <= 0 {
+			return errors.New("statefulReconnect timeout must be greater than 0")
+		}
+		*p.statefulReconnect() = statefulReconnect{
+			enabled:    true,
+			bufferSize: bufferSize,
+			timeout:    timeout,
+		}
+		return nil
+	}
+}
+
+// Protocols sets the protocols which can be used in the handshake, see RegisterProtocol. A server accepts
+// connections with these protocols. A client requests the first of the protocols which can be used on its
+// connection, so the protocols are in preference order. The protocols are chosen only by the transfer formats of
+// the connection: if the server rejects the requested protocol, the server closes the connection and Start fails.
+// The client does not try the other protocols then.
+// Default is that a server accepts all registered protocols and a client requests "json".
+func Protocols(protocols ...string) func(Party) error {
+	return func(p Party) error {
+		if len(protocols) == 0 {
+			return errors.New("at least one protocol must be set")
+		}
+		for _, protocol := range protocols {
+			if _, ok := lookupProtocol(protocol); !ok {
+				return fmt.Errorf("unsupported protocol: %v", protocol)
+			}
+		}
+		*p.protocols() = protocols
+		return nil
+	}
+}
+
+// JSONCodec configures how the "json" protocol encodes and decodes arguments, results and stream items.
+// Default is encoding/json without UseNumber and DisallowUnknownFields.
+// Numbers in the results of Client.Invoke and the items of Client.PullStream are json.Number with any options,
+// unless Unmarshal is set.
+func JSONCodec(options JSONCodecOptions) func(Party) error {
+	return func(p Party) error {
+		*p.jsonCodec() = options
+		return nil
+	}
+}
+
+func newRateLimit(perSecond float64, burst uint, policy LimitPolicy) (*rateLimit, error) {
+	if perSecond <= 0 {
+		return nil, errors.New("rate limit must be greater than 0")
+	}
+	if burst == 0 {
+		return nil, errors.New("unsupported rate limit burst 0")
+	}
+	if err := validLimitPolicy(policy); err != nil {
+		return nil, err
+	}
+	return &rateLimit{perSecond: perSecond, burst: burst, policy: policy}, nil
+}
+
+func validLimitPolicy(policy LimitPolicy) error {
+	switch policy {
+	case LimitQueue, LimitReject, LimitCloseConnection:
+		return nil
+	default:
+		return fmt.Errorf("unsupported LimitPolicy: %v", policy)
+	}
+}
+
 // StructuredLogger is the simplest logging interface for structured logging.
 // See github.com/go-kit/kit/log
 type StructuredLogger interface {
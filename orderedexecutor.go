@@ -0,0 +1,58 @@
+package signalr
+
+import (
+	"strings"
+	"sync"
+)
+
+// invocationOrder defines which hub methods are executed in the order of their invocations
+type invocationOrder struct {
+	ordered bool
+	// methods overrides ordered for single methods
+	methods map[string]bool
+}
+
+// isOrdered returns if invocations of method are executed in order
+func (o *invocationOrder) isOrdered(method string) bool {
+	if ordered, ok := o.methods[strings.ToLower(method)]; ok {
+		return ordered
+	}
+	return o.ordered
+}
+
+// orderedExecutor executes functions one after another in the order they were added.
+// It starts a goroutine only while there are functions to execute.
+type orderedExecutor struct {
+	mx      sync.Mutex
+	funcs   []func()
+	running bool
+}
+
+// run adds f to the queue
+func (e *orderedExecutor) run(f func()) {
+	e.mx.Lock()
+	e.funcs = append(e.funcs, f)
+	if e.running {
+		e.mx.Unlock()
+		return
+	}
+	e.running = true
+	e.mx.Unlock()
+	go e.work()
+}
+
+func (e *orderedExecutor) work() {
+	for {
+		e.mx.Lock()
+		if len(e.funcs) == 0 {
+			e.running = false
+			e.mx.Unlock()
+			return
+		}
+		f := e.funcs[0]
+		e.funcs[0] = nil
+		e.funcs = e.funcs[1:]
+		e.mx.Unlock()
+		f()
+	}
+}
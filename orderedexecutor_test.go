@@ -0,0 +1,76 @@
+package signalr
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"sync"
+	"time"
+)
+
+// connectOrdered connects to a server with an "append" handler, which appends its argument to the returned slice.
+// Lower arguments take longer. The "block" handler returns when release is closed.
+func connectOrdered(release chan struct{}, options ...func(Party) error) (*testingConnection, func() []int) {
+	server, err := NewServer(context.TODO(), append([]func(Party) error{SimpleHubFactory(&addHub{}),
+		Logger(log.NewLogfmtLogger(os.Stderr), false)}, options...)...)
+	Expect(err).NotTo(HaveOccurred())
+	var mx sync.Mutex
+	var appended []int
+	Expect(server.Handle("append", func(i int) {
+		time.Sleep(time.Duration(10-i) * 5 * time.Millisecond)
+		mx.Lock()
+		appended = append(appended, i)
+		mx.Unlock()
+	})).To(Succeed())
+	Expect(server.Handle("block", func() int {
+		<-release
+		return 1
+	})).To(Succeed())
+	conn := newTestingConnectionForServer()
+	go server.ServeConnection(conn)
+	return conn, func() []int {
+		mx.Lock()
+		defer mx.Unlock()
+		return append([]int(nil), appended...)
+	}
+}
+
+var _ = Describe("Ordered invocations", func() {
+	Context("When OrderedInvocations is set", func() {
+		It("should execute the invocations of a connection in the order they were received", func(done Done) {
+			conn, appended := connectOrdered(nil, OrderedInvocations(true))
+			for i := 0; i < 5; i++ {
+				conn.ClientSend(fmt.Sprintf(`{"type":1,"invocationId":"%v","target":"append","arguments":[%v]}`, i, i))
+			}
+			for i := 0; i < 5; i++ {
+				Expect(receiveCompletion(conn).InvocationID).To(Equal(fmt.Sprint(i)))
+			}
+			Expect(appended()).To(Equal([]int{0, 1, 2, 3, 4}))
+			close(done)
+		}, 2.0)
+		It("should execute the methods excluded by InvocationOrder independently", func(done Done) {
+			release := make(chan struct{})
+			conn, _ := connectOrdered(release, OrderedInvocations(true), InvocationOrder("Block", false))
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"block"}`)
+			conn.ClientSend(`{"type":1,"invocationId":"2","target":"add2","arguments":[1]}`)
+			completion := receiveCompletion(conn)
+			Expect(completion.InvocationID).To(Equal("2"))
+			close(release)
+			Expect(receiveCompletion(conn).InvocationID).To(Equal("1"))
+			close(done)
+		}, 2.0)
+	})
+	Context("When InvocationOrder is set for a method without OrderedInvocations", func() {
+		It("should execute the invocations of the method in order", func(done Done) {
+			conn, appended := connectOrdered(nil, InvocationOrder("append", true))
+			for i := 0; i < 5; i++ {
+				conn.ClientSend(fmt.Sprintf(`{"type":1,"target":"append","arguments":[%v]}`, i))
+			}
+			Eventually(appended).Should(Equal([]int{0, 1, 2, 3, 4}))
+			close(done)
+		}, 2.0)
+	})
+})
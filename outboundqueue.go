@@ -0,0 +1,54 @@
+package signalr
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"time"
+)
+
+// OverflowPolicy defines what happens when a message should be sent over a connection,
+// but the outbound queue of the connection is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the sender until the queue has room or the OutboundBlockTimeout has elapsed.
+	// If the timeout has elapsed, the message is dropped and the sender receives an error.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the queue to make room for the new message
+	OverflowDropOldest
+	// OverflowDropNewest drops the new message. The sender receives an error.
+	OverflowDropNewest
+	// OverflowDisconnect closes the connection to the slow client.
+	OverflowDisconnect
+)
+
+type outboundQueueConfig struct {
+	capacity     uint
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+	ttl          time.Duration
+	dropCounter  metrics.Counter
+}
+
+type outboundItem struct {
+	message  interface{}
+	enqueued time.Time
+	// written receives the result of the write, if set. Items with written are not dropped by the TTL
+	written chan error
+}
+
+// reasons for dropped messages, used as value of the label "reason" of the OutboundDropCounter
+const (
+	dropReasonTimeout    = "timeout"
+	dropReasonOldest     = "oldest"
+	dropReasonNewest     = "newest"
+	dropReasonTTL        = "ttl"
+	dropReasonDisconnect = "disconnect"
+)
+
+type outboundQueueFullError struct {
+	reason string
+}
+
+func (o *outboundQueueFullError) Error() string {
+	return "outbound queue full, message dropped (" + o.reason + ")"
+}
@@ -0,0 +1,253 @@
+package signalr
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"sync"
+	"time"
+)
+
+// testCounter is a metrics.Counter which counts the values for all labels together
+type testCounter struct {
+	mx    sync.Mutex
+	value float64
+}
+
+func (t *testCounter) With(...string) metrics.Counter {
+	return t
+}
+
+func (t *testCounter) Add(delta float64) {
+	t.mx.Lock()
+	t.value += delta
+	t.mx.Unlock()
+}
+
+func (t *testCounter) Value() float64 {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.value
+}
+
+// stallingConnection blocks all writes until release is closed
+type stallingConnection struct {
+	baseConnection
+	release chan struct{}
+	written chan []byte
+}
+
+func newStallingConnection() *stallingConnection {
+	return &stallingConnection{
+		baseConnection: baseConnection{ctx: context.TODO(), connectionID: "stalling"},
+		release:        make(chan struct{}),
+		written:        make(chan []byte, 100),
+	}
+}
+
+func (s *stallingConnection) Read([]byte) (n int, err error) {
+	<-s.ctx.Done()
+	return 0, s.ctx.Err()
+}
+
+func (s *stallingConnection) Write(p []byte) (n int, err error) {
+	<-s.release
+	b := make([]byte, len(p))
+	copy(b, p)
+	s.written <- b
+	return len(p), nil
+}
+
+// closingConnection sends a close message to the server after the handshake and then blocks reading
+type closingConnection struct {
+	baseConnection
+	reads chan []byte
+}
+
+func newClosingConnection(ctx context.Context) *closingConnection {
+	reads := make(chan []byte, 1)
+	reads <- []byte("{\"type\":7}\u001e")
+	return &closingConnection{baseConnection: baseConnection{ctx: ctx, connectionID: "closing"}, reads: reads}
+}
+
+func (c *closingConnection) Read(p []byte) (n int, err error) {
+	select {
+	case data := <-c.reads:
+		return copy(p, data), nil
+	case <-c.ctx.Done():
+		return 0, c.ctx.Err()
+	}
+}
+
+func (c *closingConnection) Write(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func newOutboundTestHubConnection(conn Connection, config outboundQueueConfig) hubConnection {
+	protocol := &JSONHubProtocol{}
+	protocol.setDebugLogger(log.NewNopLogger())
+	return newHubConnection(conn, protocol, 1<<15, config, nil, log.NewLogfmtLogger(os.Stderr))
+}
+
+var _ = Describe("Outbound queue", func() {
+	Context("When the queue of a stalling connection is full and the policy is OverflowDropNewest", func() {
+		It("should drop new messages without blocking and count them", func(done Done) {
+			counter := &testCounter{}
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     2,
+				policy:       OverflowDropNewest,
+				blockTimeout: time.Second,
+				dropCounter:  counter,
+			})
+			// The first message is taken by the writer, two are queued
+			Expect(hubConn.SendInvocation("", "a", nil)).To(Succeed())
+			Eventually(func() int { return len(hubConn.(*defaultHubConnection).outbound) }).Should(Equal(0))
+			Expect(hubConn.SendInvocation("", "b", nil)).To(Succeed())
+			Expect(hubConn.SendInvocation("", "c", nil)).To(Succeed())
+			for i := 0; i < 5; i++ {
+				Expect(hubConn.SendInvocation("", "d", nil)).NotTo(Succeed())
+			}
+			Expect(counter.Value()).To(Equal(5.0))
+			close(conn.release)
+			for _, target := range []string{"a", "b", "c"} {
+				Expect(string(<-conn.written)).To(ContainSubstring(`"target":"` + target + `"`))
+			}
+			hubConn.Abort()
+			close(done)
+		}, 2.0)
+	})
+	Context("When the queue of a stalling connection is full and the policy is OverflowDropOldest", func() {
+		It("should drop the oldest queued messages", func(done Done) {
+			counter := &testCounter{}
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     2,
+				policy:       OverflowDropOldest,
+				blockTimeout: time.Second,
+				dropCounter:  counter,
+			})
+			Expect(hubConn.SendInvocation("", "a", nil)).To(Succeed())
+			Eventually(func() int { return len(hubConn.(*defaultHubConnection).outbound) }).Should(Equal(0))
+			for _, target := range []string{"b", "c", "d", "e"} {
+				Expect(hubConn.SendInvocation("", target, nil)).To(Succeed())
+			}
+			Expect(counter.Value()).To(Equal(2.0))
+			close(conn.release)
+			for _, target := range []string{"a", "d", "e"} {
+				Expect(string(<-conn.written)).To(ContainSubstring(`"target":"` + target + `"`))
+			}
+			hubConn.Abort()
+			close(done)
+		}, 2.0)
+	})
+	Context("When the queue of a stalling connection is full and the policy is OverflowBlock", func() {
+		It("should return an error after the block timeout", func(done Done) {
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     1,
+				policy:       OverflowBlock,
+				blockTimeout: 100 * time.Millisecond,
+			})
+			Expect(hubConn.SendInvocation("", "a", nil)).To(Succeed())
+			Eventually(func() int { return len(hubConn.(*defaultHubConnection).outbound) }).Should(Equal(0))
+			Expect(hubConn.SendInvocation("", "b", nil)).To(Succeed())
+			start := time.Now()
+			Expect(hubConn.SendInvocation("", "c", nil)).NotTo(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+			close(conn.release)
+			hubConn.Abort()
+			close(done)
+		}, 2.0)
+	})
+	Context("When the queue of a stalling connection is full and the policy is OverflowDisconnect", func() {
+		It("should close the connection", func(done Done) {
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     1,
+				policy:       OverflowDisconnect,
+				blockTimeout: time.Second,
+			})
+			Expect(hubConn.SendInvocation("", "a", nil)).To(Succeed())
+			Eventually(func() int { return len(hubConn.(*defaultHubConnection).outbound) }).Should(Equal(0))
+			Expect(hubConn.SendInvocation("", "b", nil)).To(Succeed())
+			Expect(hubConn.SendInvocation("", "c", nil)).NotTo(Succeed())
+			Expect(hubConn.Context().Err()).To(HaveOccurred())
+			Expect(hubConn.Err().Error()).To(ContainSubstring("outbound queue full"))
+			close(conn.release)
+			close(done)
+		}, 2.0)
+	})
+	Context("When messages are older than the OutboundMessageTTL", func() {
+		It("should drop them", func(done Done) {
+			counter := &testCounter{}
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     10,
+				policy:       OverflowBlock,
+				blockTimeout: time.Second,
+				ttl:          50 * time.Millisecond,
+				dropCounter:  counter,
+			})
+			Expect(hubConn.SendInvocation("", "a", nil)).To(Succeed())
+			Eventually(func() int { return len(hubConn.(*defaultHubConnection).outbound) }).Should(Equal(0))
+			Expect(hubConn.SendInvocation("", "b", nil)).To(Succeed())
+			time.Sleep(100 * time.Millisecond)
+			close(conn.release)
+			Expect(string(<-conn.written)).To(ContainSubstring(`"target":"a"`))
+			Eventually(counter.Value).Should(Equal(1.0))
+			Expect(hubConn.SendInvocation("", "c", nil)).To(Succeed())
+			Expect(string(<-conn.written)).To(ContainSubstring(`"target":"c"`))
+			hubConn.Abort()
+			close(done)
+		}, 2.0)
+	})
+	Context("When the connection is closed while messages are queued", func() {
+		It("should send them before the close message", func(done Done) {
+			conn := newStallingConnection()
+			hubConn := newOutboundTestHubConnection(conn, outboundQueueConfig{
+				capacity:     10,
+				policy:       OverflowBlock,
+				blockTimeout: time.Second,
+			})
+			for _, target := range []string{"a", "b", "c"} {
+				Expect(hubConn.SendInvocation("", target, nil)).To(Succeed())
+			}
+			closed := make(chan error, 1)
+			go func() { closed <- hubConn.Close("", false) }()
+			close(conn.release)
+			for _, target := range []string{"a", "b", "c"} {
+				Expect(string(<-conn.written)).To(ContainSubstring(`"target":"` + target + `"`))
+			}
+			Expect(string(<-conn.written)).To(ContainSubstring(`"type":7`))
+			Expect(<-closed).NotTo(HaveOccurred())
+			hubConn.Abort()
+			close(done)
+		}, 2.0)
+	})
+	Context("When the loop ends on a close message and the connection stays open", func() {
+		It("should end the hubConnection, so its reader and writer end", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s, err := NewServer(context.TODO(), SimpleHubFactory(&invocationHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			protocol := &JSONHubProtocol{}
+			l := newLoop(s.(*server), newClosingConnection(ctx), protocol, nil)
+			l.Run(make(chan struct{}, 1))
+			Expect(l.hubConn.Context().Err()).To(HaveOccurred())
+			Expect(ctx.Err()).NotTo(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When OutboundQueueCapacity is 0", func() {
+		It("should return an error", func(done Done) {
+			_, err := NewServer(context.TODO(), UseHub(&singleHub{}), OutboundQueueCapacity(0))
+			Expect(err).To(HaveOccurred())
+			close(done)
+		})
+	})
+})
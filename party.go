@@ -3,6 +3,7 @@ package signalr
 import (
 	"context"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 	"time"
 )
 
@@ -16,6 +17,8 @@ type Party interface {
 	onDisconnected(hc hubConnection)
 
 	invocationTarget(hc hubConnection) interface{}
+	invocationMethod(name string) (*hubMethod, bool)
+	newConnectionHubContext(hc hubConnection) HubContext
 
 	timeout() time.Duration
 	setTimeout(timeout time.Duration)
@@ -43,6 +46,33 @@ type Party interface {
 
 	maximumReceiveMessageSize() uint
 	setMaximumReceiveMessageSize(size uint)
+
+	outboundQueueCapacity() uint
+	setOutboundQueueCapacity(capacity uint)
+
+	outboundOverflowPolicy() OverflowPolicy
+	setOutboundOverflowPolicy(policy OverflowPolicy)
+
+	outboundBlockTimeout() time.Duration
+	setOutboundBlockTimeout(timeout time.Duration)
+
+	outboundMessageTTL() time.Duration
+	setOutboundMessageTTL(ttl time.Duration)
+
+	outboundDropCounter() metrics.Counter
+	setOutboundDropCounter(counter metrics.Counter)
+
+	invocationLimits() *invocationLimits
+
+	invocationOrder() *invocationOrder
+
+	webSocketCompression() *webSocketCompression
+
+	statefulReconnect() *statefulReconnect
+
+	protocols() *[]string
+
+	jsonCodec() *JSONCodecOptions
 }
 
 func newPartyBase(parentContext context.Context, info log.Logger, dbg log.Logger) partyBase {
@@ -57,6 +87,9 @@ func newPartyBase(parentContext context.Context, info log.Logger, dbg log.Logger
 		_streamBufferCapacity:      10,
 		_maximumReceiveMessageSize: 1 << 15, // 32KB
 		_enableDetailedErrors:      false,
+		_outboundQueueCapacity:     256,
+		_outboundOverflowPolicy:    OverflowBlock,
+		_outboundBlockTimeout:      time.Second * 5,
 		info:                       info,
 		dbg:                        dbg,
 	}
@@ -72,6 +105,17 @@ type partyBase struct {
 	_streamBufferCapacity      uint
 	_maximumReceiveMessageSize uint
 	_enableDetailedErrors      bool
+	_outboundQueueCapacity     uint
+	_outboundOverflowPolicy    OverflowPolicy
+	_outboundBlockTimeout      time.Duration
+	_outboundMessageTTL        time.Duration
+	_outboundDropCounter       metrics.Counter
+	_invocationLimits          invocationLimits
+	_invocationOrder           invocationOrder
+	_webSocketCompression      webSocketCompression
+	_statefulReconnect         statefulReconnect
+	_protocols                 []string
+	_jsonCodec                 JSONCodecOptions
 	info                       StructuredLogger
 	dbg                        StructuredLogger
 }
@@ -140,6 +184,70 @@ func (p *partyBase) setEnableDetailedErrors(enable bool) {
 	p._enableDetailedErrors = enable
 }
 
+func (p *partyBase) outboundQueueCapacity() uint {
+	return p._outboundQueueCapacity
+}
+
+func (p *partyBase) setOutboundQueueCapacity(capacity uint) {
+	p._outboundQueueCapacity = capacity
+}
+
+func (p *partyBase) outboundOverflowPolicy() OverflowPolicy {
+	return p._outboundOverflowPolicy
+}
+
+func (p *partyBase) setOutboundOverflowPolicy(policy OverflowPolicy) {
+	p._outboundOverflowPolicy = policy
+}
+
+func (p *partyBase) outboundBlockTimeout() time.Duration {
+	return p._outboundBlockTimeout
+}
+
+func (p *partyBase) setOutboundBlockTimeout(timeout time.Duration) {
+	p._outboundBlockTimeout = timeout
+}
+
+func (p *partyBase) outboundMessageTTL() time.Duration {
+	return p._outboundMessageTTL
+}
+
+func (p *partyBase) setOutboundMessageTTL(ttl time.Duration) {
+	p._outboundMessageTTL = ttl
+}
+
+func (p *partyBase) outboundDropCounter() metrics.Counter {
+	return p._outboundDropCounter
+}
+
+func (p *partyBase) setOutboundDropCounter(counter metrics.Counter) {
+	p._outboundDropCounter = counter
+}
+
+func (p *partyBase) invocationLimits() *invocationLimits {
+	return &p._invocationLimits
+}
+
+func (p *partyBase) invocationOrder() *invocationOrder {
+	return &p._invocationOrder
+}
+
+func (p *partyBase) webSocketCompression() *webSocketCompression {
+	return &p._webSocketCompression
+}
+
+func (p *partyBase) statefulReconnect() *statefulReconnect {
+	return &p._statefulReconnect
+}
+
+func (p *partyBase) protocols() *[]string {
+	return &p._protocols
+}
+
+func (p *partyBase) jsonCodec() *JSONCodecOptions {
+	return &p._jsonCodec
+}
+
 func (p *partyBase) setLoggers(info StructuredLogger, dbg StructuredLogger) {
 	p.info = info
 	p.dbg = dbg
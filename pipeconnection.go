@@ -0,0 +1,16 @@
+package signalr
+
+import (
+	"context"
+	"net"
+)
+
+// NewPipeConnectionPair creates two Connections which are connected in memory, e.g. to run a Server and a Client
+// in one process without network. Pass serverConn to Server.ServeConnection and clientConn to NewClient.
+// Both connections have the same connectionID and support timeouts. When ctx is done, both connections are closed
+// and reading from them returns an error.
+func NewPipeConnectionPair(ctx context.Context) (serverConn Connection, clientConn Connection) {
+	serverEnd, clientEnd := net.Pipe()
+	connectionID := newConnectionID()
+	return newNetConnection(ctx, connectionID, serverEnd), newNetConnection(ctx, connectionID, clientEnd)
+}
@@ -0,0 +1,53 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"os"
+	"time"
+)
+
+var _ = Describe("Pipe connections", func() {
+	Context("When a server and a client are connected over a pipe connection pair", func() {
+		It("should serve invocations and end when the context is done", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			server, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			serverConn, clientConn := NewPipeConnectionPair(ctx)
+			Expect(serverConn.ConnectionID()).To(Equal(clientConn.ConnectionID()))
+			served := make(chan struct{})
+			go func() {
+				server.ServeConnection(serverConn)
+				close(served)
+			}()
+			client, err := NewClient(context.TODO(), clientConn,
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Start()).To(Succeed())
+			result := <-client.Invoke("Add2", 1)
+			Expect(result.Error).NotTo(HaveOccurred())
+			Expect(result.Value).To(Equal(json.Number("3")))
+			cancel()
+			<-served
+			Eventually(func() error { return (<-client.Invoke("Add2", 1)).Error }).Should(HaveOccurred())
+			close(done)
+		}, 2.0)
+	})
+	Context("When nothing is written to a pipe connection with timeout", func() {
+		It("should return an error when reading after the timeout", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			serverConn, _ := NewPipeConnectionPair(ctx)
+			serverConn.SetTimeout(50 * time.Millisecond)
+			start := time.Now()
+			_, err := serverConn.Read(make([]byte, 1))
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+			close(done)
+		}, 2.0)
+	})
+})
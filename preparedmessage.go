@@ -0,0 +1,46 @@
+package signalr
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+)
+
+// preparedMessage is a message which is sent to many connections.
+// It is encoded only once per HubProtocol type. The encoded bytes are shared by all connections and must not be modified.
+type preparedMessage struct {
+	message interface{}
+	mx      sync.Mutex
+	encoded map[reflect.Type]*encodedMessage
+}
+
+type encodedMessage struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func newPreparedMessage(message interface{}) *preparedMessage {
+	return &preparedMessage{
+		message: message,
+		encoded: make(map[reflect.Type]*encodedMessage),
+	}
+}
+
+// encode returns the message encoded by protocol. Concurrent callers with the same protocol type wait for one encoding.
+func (p *preparedMessage) encode(protocol HubProtocol) ([]byte, error) {
+	protocolType := reflect.TypeOf(protocol)
+	p.mx.Lock()
+	e, ok := p.encoded[protocolType]
+	if !ok {
+		e = &encodedMessage{}
+		p.encoded[protocolType] = e
+	}
+	p.mx.Unlock()
+	e.once.Do(func() {
+		var buf bytes.Buffer
+		e.err = protocol.WriteMessage(p.message, &buf)
+		e.data = buf.Bytes()
+	})
+	return e.data, e.err
+}
@@ -0,0 +1,128 @@
+package signalr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// debugLoggable is implemented by HubProtocols which log the read and written messages
+type debugLoggable interface {
+	setDebugLogger(dbg StructuredLogger)
+}
+
+// The SignalR messages, which HubProtocols registered with RegisterProtocol read and write.
+// HubMessage is used for messages without further fields, like the ping message with Type 6.
+type (
+	HubMessage              = hubMessage
+	InvocationMessage       = invocationMessage
+	StreamItemMessage       = streamItemMessage
+	CompletionMessage       = completionMessage
+	CancelInvocationMessage = cancelInvocationMessage
+	CloseMessage            = closeMessage
+)
+
+// TransferFormats of HubProtocols. Text protocols must end each message with the record separator 0x1e.
+const (
+	TransferFormatText   = "Text"
+	TransferFormatBinary = "Binary"
+)
+
+type registeredProtocol struct {
+	factory        func() HubProtocol
+	transferFormat string
+	// maxVersion is the highest version of the protocol. All versions from 1 to maxVersion are supported.
+	maxVersion int
+}
+
+var protocolRegistry = struct {
+	mx        sync.RWMutex
+	protocols map[string]registeredProtocol
+}{
+	protocols: map[string]registeredProtocol{
+		"json": {
+			factory:        func() HubProtocol { return &JSONHubProtocol{} },
+			transferFormat: TransferFormatText,
+			maxVersion:     statefulReconnectVersion,
+		},
+	},
+}
+
+// RegisterProtocol makes a HubProtocol available under name. Servers accept it in the handshake, clients can request it.
+// See the Protocols option. factory creates the HubProtocol for each connection. transferFormat is either
+// TransferFormatText or TransferFormatBinary. Over http, the server only offers transports which can transfer the
+// formats of its protocols. Registered protocols support protocol version 1, so connections using them can not
+// use stateful reconnect. The built-in protocol "json" can not be replaced.
+func RegisterProtocol(name string, factory func() HubProtocol, transferFormat string) error {
+	if name == "" {
+		return errors.New("protocol name must not be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("factory of protocol %v must not be nil", name)
+	}
+	if transferFormat != TransferFormatText && transferFormat != TransferFormatBinary {
+		return fmt.Errorf("unsupported transfer format of protocol %v: %v", name, transferFormat)
+	}
+	protocolRegistry.mx.Lock()
+	defer protocolRegistry.mx.Unlock()
+	if name == "json" {
+		return errors.New("protocol json can not be replaced")
+	}
+	protocolRegistry.protocols[name] = registeredProtocol{
+		factory:        factory,
+		transferFormat: transferFormat,
+		maxVersion:     1,
+	}
+	return nil
+}
+
+func lookupProtocol(name string) (registeredProtocol, bool) {
+	protocolRegistry.mx.RLock()
+	defer protocolRegistry.mx.RUnlock()
+	protocol, ok := protocolRegistry.protocols[name]
+	return protocol, ok
+}
+
+// registeredProtocolNames returns the names of all registered protocols
+func registeredProtocolNames() []string {
+	protocolRegistry.mx.RLock()
+	defer protocolRegistry.mx.RUnlock()
+	names := make([]string, 0, len(protocolRegistry.protocols))
+	for name := range protocolRegistry.protocols {
+		names = append(names, name)
+	}
+	return names
+}
+
+// transferFormats returns the transfer formats of the protocols, nil means all registered protocols
+func transferFormats(protocols []string) []string {
+	if protocols == nil {
+		protocols = registeredProtocolNames()
+	}
+	var text, binary bool
+	for _, name := range protocols {
+		if registered, ok := lookupProtocol(name); ok {
+			text = text || registered.transferFormat == TransferFormatText
+			binary = binary || registered.transferFormat == TransferFormatBinary
+		}
+	}
+	var formats []string
+	if text {
+		formats = append(formats, TransferFormatText)
+	}
+	if binary {
+		formats = append(formats, TransferFormatBinary)
+	}
+	return formats
+}
+
+// protocolsWithTransferFormats returns the protocols which use one of the formats
+func protocolsWithTransferFormats(protocols []string, formats []string) []string {
+	var result []string
+	for _, name := range protocols {
+		if registered, ok := lookupProtocol(name); ok && containsString(formats, registered.transferFormat) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"os"
+)
+
+// lengthPrefixedProtocol is a binary protocol which only uses the exported API.
+// Each message is JSON with a 4 byte length prefix.
+type lengthPrefixedProtocol struct{}
+
+func (l *lengthPrefixedProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, error) {
+	if buf.Len() < 4 {
+		return nil, false, nil
+	}
+	size := int(binary.BigEndian.Uint32(buf.Bytes()[:4]))
+	if buf.Len() < 4+size {
+		return nil, false, nil
+	}
+	buf.Next(4)
+	data := buf.Next(size)
+	var message HubMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, true, err
+	}
+	switch message.Type {
+	case 1:
+		var raw struct {
+			Target       string            `json:"target"`
+			InvocationID string            `json:"invocationId"`
+			Arguments    []json.RawMessage `json:"arguments"`
+		}
+		err := json.Unmarshal(data, &raw)
+		invocation := InvocationMessage{Type: 1, Target: raw.Target, InvocationID: raw.InvocationID,
+			Arguments: make([]interface{}, len(raw.Arguments))}
+		for i, argument := range raw.Arguments {
+			invocation.Arguments[i] = argument
+		}
+		return invocation, true, err
+	case 3:
+		var completion CompletionMessage
+		err := json.Unmarshal(data, &completion)
+		return completion, true, err
+	case 7:
+		var closeMessage CloseMessage
+		err := json.Unmarshal(data, &closeMessage)
+		return closeMessage, true, err
+	default:
+		return message, true, nil
+	}
+}
+
+func (l *lengthPrefixedProtocol) WriteMessage(message interface{}, writer io.Writer) error {
+	switch message.(type) {
+	case HubMessage, InvocationMessage, StreamItemMessage, CompletionMessage, CancelInvocationMessage, CloseMessage:
+	default:
+		return fmt.Errorf("unexpected message %#v", message)
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	prefixed := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(prefixed, uint32(len(data)))
+	copy(prefixed[4:], data)
+	_, err = writer.Write(prefixed)
+	return err
+}
+
+func (l *lengthPrefixedProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
+	return json.Unmarshal(argument.(json.RawMessage), value)
+}
+
+var _ = Describe("Protocol registry", func() {
+	BeforeEach(func() {
+		Expect(RegisterProtocol("lengthprefixed", func() HubProtocol { return &lengthPrefixedProtocol{} },
+			TransferFormatBinary)).To(Succeed())
+	})
+	Context("When a protocol is registered", func() {
+		It("should reject invalid registrations", func() {
+			factory := func() HubProtocol { return &lengthPrefixedProtocol{} }
+			Expect(RegisterProtocol("", factory, TransferFormatBinary)).NotTo(Succeed())
+			Expect(RegisterProtocol("invalid", nil, TransferFormatBinary)).NotTo(Succeed())
+			Expect(RegisterProtocol("invalid", factory, "Morse")).NotTo(Succeed())
+			Expect(RegisterProtocol("json", factory, TransferFormatBinary)).NotTo(Succeed())
+		})
+		It("should be accepted by the Protocols option", func() {
+			_, err := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), Protocols("lengthprefixed", "json"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = NewServer(context.TODO(), SimpleHubFactory(&addHub{}), Protocols("unregistered"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When a client prefers a registered protocol", func() {
+		It("should connect and invoke with it", func(done Done) {
+			protocols := make(chan string, 1)
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&addHub{}),
+				AcceptHandshake(func(handshake Handshake) error {
+					protocols <- handshake.Protocol
+					return nil
+				}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			cliConn, srvConn := newClientServerConnections()
+			go server.ServeConnection(srvConn)
+			client, _ := NewClient(context.TODO(), cliConn, Protocols("lengthprefixed", "json"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(client.Start()).To(Succeed())
+			Expect(<-protocols).To(Equal("lengthprefixed"))
+			Expect(<-client.Invoke("Add2", 1)).To(Equal(InvokeResult{Value: 3.0}))
+			Expect(client.Stop()).To(Succeed())
+			server.cancel()
+			close(done)
+		})
+		It("should be rejected by a server which does not accept it", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), Protocols("json"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			cliConn, srvConn := newClientServerConnections()
+			go server.ServeConnection(srvConn)
+			client, _ := NewClient(context.TODO(), cliConn, Protocols("lengthprefixed"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(client.Start()).To(MatchError("the protocol 'lengthprefixed' is not supported"))
+			server.cancel()
+			close(done)
+		})
+	})
+	Context("When the server rejects the first protocol of a client", func() {
+		It("should fail without trying the next protocol", func(done Done) {
+			server, _ := NewServer(context.TODO(), SimpleHubFactory(&addHub{}), Protocols("json"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			cliConn, srvConn := newClientServerConnections()
+			go server.ServeConnection(srvConn)
+			client, _ := NewClient(context.TODO(), cliConn, Protocols("lengthprefixed", "json"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(client.Start()).To(MatchError("the protocol 'lengthprefixed' is not supported"))
+			server.cancel()
+			close(done)
+		})
+	})
+	Context("When a server uses a binary protocol over http", func() {
+		var httpServer *httptest.Server
+		var cancel context.CancelFunc
+		start := func(options ...func(Party) error) {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			server, err := NewServer(ctx, append([]func(Party) error{SimpleHubFactory(&addHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+			Expect(err).NotTo(HaveOccurred())
+			httpServer = httptest.NewServer(server.Handler())
+		}
+		AfterEach(func() {
+			cancel()
+			httpServer.Close()
+		})
+		It("should offer the binary transfer format only on WebSockets", func() {
+			start(Protocols("lengthprefixed"))
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			Expect(nr.getTransferFormats("WebSockets")).To(Equal([]string{TransferFormatBinary}))
+			Expect(nr.getTransferFormats("ServerSentEvents")).To(BeNil())
+		})
+		It("should offer both transfer formats for text and binary protocols", func() {
+			start(Protocols("json", "lengthprefixed"))
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			Expect(nr.getTransferFormats("WebSockets")).To(Equal([]string{TransferFormatText, TransferFormatBinary}))
+			Expect(nr.getTransferFormats("ServerSentEvents")).To(Equal([]string{TransferFormatText}))
+		})
+		It("should send binary WebSocket messages", func(done Done) {
+			start(Protocols("lengthprefixed"))
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1")
+			ws, _, err := websocket.Dial(context.TODO(), httpServer.URL+"?id="+nr.ConnectionToken, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Write(context.TODO(), websocket.MessageBinary,
+				[]byte("{\"protocol\":\"lengthprefixed\",\"version\":1}\u001e"))).To(Succeed())
+			messageType, data, err := ws.Read(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messageType).To(Equal(websocket.MessageBinary))
+			Expect(string(data)).To(Equal("{}\u001e"))
+			close(done)
+		})
+		It("should connect NewHTTPClient with the binary protocol", func(done Done) {
+			start(Protocols("lengthprefixed"))
+			client, err := NewHTTPClient(context.TODO(), httpServer.URL, Protocols("json", "lengthprefixed"),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Start()).To(Succeed())
+			Expect(<-client.Invoke("Add2", 2)).To(Equal(InvokeResult{Value: 4.0}))
+			Expect(client.Stop()).To(Succeed())
+			close(done)
+		})
+	})
+})
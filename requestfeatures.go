@@ -0,0 +1,104 @@
+package signalr
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// RequestFeatures is a read-only snapshot of the request which created a connection.
+// For connections over http, it holds the remote address, URL, headers and TLS state of the
+// connect request. For connections over plain network connections, only the remote address
+// and the TLS state are known.
+type RequestFeatures struct {
+	remoteAddr string
+	url        url.URL
+	header     http.Header
+	tls        *tls.ConnectionState
+}
+
+// NewRequestFeatures takes a snapshot of request
+func NewRequestFeatures(request *http.Request) *RequestFeatures {
+	r := &RequestFeatures{
+		remoteAddr: request.RemoteAddr,
+		header:     request.Header.Clone(),
+	}
+	if request.URL != nil {
+		r.url = *request.URL
+	}
+	if request.TLS != nil {
+		state := *request.TLS
+		r.tls = &state
+	}
+	return r
+}
+
+// NewRequestFeaturesFromConn takes a snapshot of the remote address and, for *tls.Conn, of the TLS state of conn
+func NewRequestFeaturesFromConn(conn net.Conn) *RequestFeatures {
+	r := &RequestFeatures{
+		header: http.Header{},
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		r.remoteAddr = addr.String()
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		r.tls = &state
+	}
+	return r
+}
+
+// RemoteAddr returns the network address of the client, e.g. "192.0.2.1:25"
+func (r *RequestFeatures) RemoteAddr() string {
+	return r.remoteAddr
+}
+
+// URL returns a copy of the request URL
+func (r *RequestFeatures) URL() url.URL {
+	return r.url
+}
+
+// Query returns the parsed query parameters of the request URL
+func (r *RequestFeatures) Query() url.Values {
+	return r.url.Query()
+}
+
+// Header returns the first value of the request header with the given key
+func (r *RequestFeatures) Header(key string) string {
+	return r.header.Get(key)
+}
+
+// Headers returns a copy of all request headers
+func (r *RequestFeatures) Headers() http.Header {
+	return r.header.Clone()
+}
+
+// Cookie returns the named cookie of the request or http.ErrNoCookie if not found
+func (r *RequestFeatures) Cookie(name string) (*http.Cookie, error) {
+	return (&http.Request{Header: r.header}).Cookie(name)
+}
+
+// Cookies returns all cookies of the request
+func (r *RequestFeatures) Cookies() []*http.Cookie {
+	return (&http.Request{Header: r.header}).Cookies()
+}
+
+// TLS returns the TLS state of the request or nil, if the request was not sent over TLS
+func (r *RequestFeatures) TLS() *tls.ConnectionState {
+	if r.tls == nil {
+		return nil
+	}
+	state := *r.tls
+	return &state
+}
+
+// ConnectionWithRequestFeatures is a Connection which knows the request it was created by.
+// The connections created by the http server implement it.
+type ConnectionWithRequestFeatures interface {
+	Connection
+	RequestFeatures() *RequestFeatures
+}
+
+// emptyRequestFeatures are returned for connections which do not know their request
+var emptyRequestFeatures = &RequestFeatures{header: http.Header{}}
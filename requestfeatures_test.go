@@ -0,0 +1,102 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"strings"
+)
+
+type featuresHub struct {
+	Hub
+}
+
+func (f *featuresHub) Features() map[string]string {
+	features := f.RequestFeatures()
+	result := map[string]string{
+		"remoteAddr": features.RemoteAddr(),
+		"header":     features.Header("X-Test"),
+		"query":      features.Query().Get("q"),
+	}
+	if cookie, err := features.Cookie("session"); err == nil {
+		result["cookie"] = cookie.Value
+	}
+	return result
+}
+
+var _ = Describe("RequestFeatures", func() {
+	Context("When a hub method is invoked over a WebSocket connection", func() {
+		It("should see the request which created the connection", func(done Done) {
+			server, err := NewServer(context.TODO(), SimpleHubFactory(&featuresHub{}))
+			Expect(err).NotTo(HaveOccurred())
+			httpServer := httptest.NewServer(server.Handler())
+			defer httpServer.Close()
+			header := http.Header{}
+			header.Set("X-Test", "header")
+			header.Set("Cookie", "session=cookie")
+			ws, _, err := websocket.Dial(context.TODO(), httpServer.URL+"?q=query", &websocket.DialOptions{HTTPHeader: header})
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = ws.Close(websocket.StatusNormalClosure, "") }()
+			Expect(ws.Write(context.TODO(), websocket.MessageText, []byte("{\"protocol\":\"json\",\"version\":1}\u001e"))).To(Succeed())
+			Expect(ws.Write(context.TODO(), websocket.MessageText, []byte("{\"type\":1,\"invocationId\":\"1\",\"target\":\"features\"}\u001e"))).To(Succeed())
+			for {
+				_, data, err := ws.Read(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				var completion struct {
+					Type   int               `json:"type"`
+					Result map[string]string `json:"result"`
+				}
+				if err := json.Unmarshal([]byte(strings.TrimSuffix(string(data), "\u001e")), &completion); err == nil && completion.Type == 3 {
+					Expect(completion.Result["header"]).To(Equal("header"))
+					Expect(completion.Result["query"]).To(Equal("query"))
+					Expect(completion.Result["cookie"]).To(Equal("cookie"))
+					Expect(completion.Result["remoteAddr"]).To(HavePrefix("127.0.0.1:"))
+					break
+				}
+			}
+			close(done)
+		}, 5.0)
+	})
+	Context("When the connection does not know its request", func() {
+		It("should return empty RequestFeatures", func(done Done) {
+			conn := connect(&featuresHub{})
+			conn.ClientSend(`{"type":1,"invocationId":"1","target":"features"}`)
+			Expect(receiveCompletion(conn).Result).To(Equal(map[string]interface{}{"remoteAddr": "", "header": "", "query": ""}))
+			close(done)
+		}, 2.0)
+	})
+	Context("When the request is changed after the snapshot", func() {
+		It("should not change the RequestFeatures", func() {
+			request := httptest.NewRequest("GET", "http://example.com/hub?q=1", nil)
+			request.Header.Set("X-Test", "before")
+			features := NewRequestFeatures(request)
+			request.Header.Set("X-Test", "after")
+			request.URL.RawQuery = "q=2"
+			features.Headers().Set("X-Test", "changed")
+			Expect(features.Header("X-Test")).To(Equal("before"))
+			Expect(features.Query().Get("q")).To(Equal("1"))
+			Expect(features.RemoteAddr()).To(Equal(request.RemoteAddr))
+			Expect(features.TLS()).To(BeNil())
+		})
+	})
+	Context("When the RequestFeatures are taken from a net.Conn", func() {
+		It("should contain the remote address", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = listener.Close() }()
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = conn.Close() }()
+			features := NewRequestFeaturesFromConn(conn)
+			Expect(features.RemoteAddr()).To(Equal(listener.Addr().String()))
+			Expect(features.Header("X-Test")).To(BeEmpty())
+			_, err = features.Cookie("session")
+			Expect(err).To(Equal(http.ErrNoCookie))
+		})
+	})
+})
@@ -0,0 +1,384 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"github.com/rotisserie/eris"
+	"sync"
+	"time"
+)
+
+// statefulReconnect holds the settings for stateful reconnect
+type statefulReconnect struct {
+	enabled    bool
+	bufferSize uint
+	timeout    time.Duration
+}
+
+// errTransportLost is returned by a resumableConnection when its transport failed.
+// Reading from the resumableConnection waits for the next transport.
+var errTransportLost = errors.New("transport lost")
+
+// resumableConnection is the Connection of a hubConnection with stateful reconnect.
+// When its transport is lost, a new transport can be attached within the reconnect timeout.
+// The sequenced messages which were not acknowledged by the other Party are resent over the new transport.
+type resumableConnection struct {
+	baseConnection
+	cancel context.CancelFunc
+	buffer *messageBuffer
+	// reconnectTimeout is the time in which a new transport must be attached after the transport was lost
+	reconnectTimeout time.Duration
+	// dial creates a new transport after the transport was lost. Only clients dial, servers wait for the client.
+	dial func(ctx context.Context) (Connection, error)
+	// attachMx serializes attach, which resends messages without holding mx
+	attachMx  sync.Mutex
+	mx        sync.Mutex
+	transport *attachedTransport
+	// changed is closed when a transport is attached
+	changed        chan struct{}
+	expiry         *time.Timer
+	protocol       HubProtocol
+	readLimit      int64
+	transferFormat string
+	// disabled is set when the negotiated protocol version does not support stateful reconnect
+	disabled bool
+}
+
+type attachedTransport struct {
+	conn Connection
+	// done is closed when the transport is no longer used
+	done chan struct{}
+}
+
+func newResumableConnection(ctx context.Context, connectionID string, settings *statefulReconnect,
+	dial func(ctx context.Context) (Connection, error)) *resumableConnection {
+	ctx, cancel := context.WithCancel(ctx)
+	return &resumableConnection{
+		baseConnection: baseConnection{
+			ctx:          ctx,
+			connectionID: connectionID,
+		},
+		cancel:           cancel,
+		buffer:           newMessageBuffer(settings.bufferSize),
+		reconnectTimeout: settings.timeout,
+		dial:             dial,
+		changed:          make(chan struct{}),
+	}
+}
+
+// attach makes conn the transport of the connection. If a transport has been attached before, the other Party is
+// told from where on messages are resent and the unacknowledged messages are resent.
+// The returned channel is closed when the transport is no longer used.
+func (r *resumableConnection) attach(conn Connection) (<-chan struct{}, error) {
+	r.attachMx.Lock()
+	defer r.attachMx.Unlock()
+	r.mx.Lock()
+	if err := r.ctx.Err(); err != nil {
+		r.mx.Unlock()
+		return nil, eris.Wrap(err, "resumableConnection canceled")
+	}
+	conn.SetTimeout(r.timeout)
+	if limiter, ok := conn.(readLimiter); ok && r.readLimit > 0 {
+		limiter.setReadLimit(r.readLimit)
+	}
+	if formatter, ok := conn.(transferFormatter); ok && r.transferFormat != "" {
+		if err := formatter.setTransferFormat(r.transferFormat); err != nil {
+			r.mx.Unlock()
+			return nil, err
+		}
+	}
+	protocol := r.protocol
+	r.mx.Unlock()
+	// Without protocol, no hubConnection has been created and nothing was sent
+	if protocol != nil {
+		if err := r.resend(conn, protocol); err != nil {
+			return nil, err
+		}
+	} else {
+		r.mx.Lock()
+	}
+	// resend returns with mx locked, so no message is sent between the resent messages and the switch
+	defer r.mx.Unlock()
+	if err := r.ctx.Err(); err != nil {
+		return nil, eris.Wrap(err, "resumableConnection canceled")
+	}
+	if r.transport != nil {
+		// Replaced before its loss was noticed
+		close(r.transport.done)
+	}
+	if r.expiry != nil {
+		r.expiry.Stop()
+		r.expiry = nil
+	}
+	if c, ok := conn.(ConnectionWithRequestFeatures); ok {
+		r.requestFeatures = c.RequestFeatures()
+	}
+	r.transport = &attachedTransport{conn: conn, done: make(chan struct{})}
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return r.transport.done, nil
+}
+
+// resend writes the sequence message and the unacknowledged messages to conn. It does not hold mx while writing,
+// so a slow transport does not block the connection. Messages which are sent meanwhile are written afterwards,
+// until none are left. When resend returns without error, mx is locked.
+func (r *resumableConnection) resend(conn Connection, protocol HubProtocol) error {
+	first, messages := r.buffer.resend()
+	if err := protocol.WriteMessage(sequenceMessage{Type: 9, SequenceID: first}, conn); err != nil {
+		return err
+	}
+	next := first + uint64(len(messages))
+	for {
+		for _, data := range messages {
+			if _, err := conn.Write(data); err != nil {
+				return err
+			}
+		}
+		r.mx.Lock()
+		var following uint64
+		if messages, following = r.buffer.since(next); len(messages) == 0 {
+			return nil
+		}
+		r.mx.Unlock()
+		next = following
+	}
+}
+
+// lose detaches the failed transport t. If no new transport is attached within the timeout, the connection ends.
+func (r *resumableConnection) lose(t *attachedTransport) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.transport != t {
+		return
+	}
+	r.transport = nil
+	close(t.done)
+	if r.disabled {
+		r.cancel()
+	}
+	if r.ctx.Err() != nil {
+		return
+	}
+	var expiry *time.Timer
+	expiry = time.AfterFunc(r.reconnectTimeout, func() {
+		r.mx.Lock()
+		defer r.mx.Unlock()
+		if r.expiry == expiry {
+			r.cancel()
+		}
+	})
+	r.expiry = expiry
+	if r.dial != nil {
+		go r.reconnect()
+	}
+}
+
+// reconnect dials new transports until one is attached or the connection has ended
+func (r *resumableConnection) reconnect() {
+	delay := 50 * time.Millisecond
+	for {
+		if conn, err := r.dial(r.ctx); err == nil {
+			if _, err = r.attach(conn); err == nil {
+				return
+			}
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.ctx.Done():
+			return
+		}
+		if delay *= 2; delay > time.Second {
+			delay = time.Second
+		}
+	}
+}
+
+// disable makes the connection end with its transport
+func (r *resumableConnection) disable() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.disabled = true
+}
+
+// enabled returns if the connection can be resumed with a new transport
+func (r *resumableConnection) enabled() bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return !r.disabled
+}
+
+// close ends the connection
+func (r *resumableConnection) close() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.cancel()
+	if r.transport != nil {
+		close(r.transport.done)
+		r.transport = nil
+	}
+}
+
+// current returns the current transport. If there is none, it waits for the next one if wait is set.
+func (r *resumableConnection) current(wait bool) (*attachedTransport, error) {
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return nil, eris.Wrap(err, "resumableConnection canceled")
+		}
+		r.mx.Lock()
+		t, changed := r.transport, r.changed
+		r.mx.Unlock()
+		if t != nil {
+			return t, nil
+		}
+		if !wait {
+			return nil, errTransportLost
+		}
+		select {
+		case <-changed:
+		case <-r.ctx.Done():
+		}
+	}
+}
+
+// Read reads from the current transport or waits for the next one. If the transport fails, errTransportLost is
+// returned and the data of a partially read message is lost.
+func (r *resumableConnection) Read(p []byte) (int, error) {
+	t, err := r.current(true)
+	if err != nil {
+		return 0, err
+	}
+	n, err := t.conn.Read(p)
+	if err != nil {
+		r.lose(t)
+		return n, errTransportLost
+	}
+	return n, nil
+}
+
+// Write writes to the current transport. Without transport, errTransportLost is returned.
+// Sequenced messages have to be written with writeSequenced.
+func (r *resumableConnection) Write(p []byte) (int, error) {
+	t, err := r.current(false)
+	if err != nil {
+		return 0, err
+	}
+	n, err := t.conn.Write(p)
+	if err != nil {
+		r.lose(t)
+		return n, errTransportLost
+	}
+	return n, nil
+}
+
+// writeSequenced buffers the encoded sequenced message and writes it to the current transport.
+// If there is no transport or the transport fails, the message is resent with the next transport.
+// When the buffer is full, writeSequenced waits until the other Party acknowledges received messages.
+func (r *resumableConnection) writeSequenced(ctx context.Context, data []byte) error {
+	if err := r.buffer.waitForSpace(ctx, uint(len(data))); err != nil {
+		return err
+	}
+	if err := r.ctx.Err(); err != nil {
+		return eris.Wrap(err, "resumableConnection canceled")
+	}
+	// Buffer and fetch the transport at once, so attach either resends the message or the message is written
+	// to the new transport after the resent messages
+	r.mx.Lock()
+	r.buffer.add(data)
+	t := r.transport
+	r.mx.Unlock()
+	if t != nil {
+		if _, err := t.conn.Write(data); err != nil {
+			r.lose(t)
+		}
+	}
+	return nil
+}
+
+// receive handles the stateful reconnect part of a received message. It returns false if the message should
+// not be passed to the loop.
+func (r *resumableConnection) receive(message interface{}) (bool, error) {
+	switch m := message.(type) {
+	case ackMessage:
+		r.buffer.ack(m.SequenceID)
+		return false, nil
+	case sequenceMessage:
+		return false, r.buffer.sequence(m.SequenceID)
+	default:
+		if isSequenced(message) {
+			return r.buffer.receive(), nil
+		}
+		return true, nil
+	}
+}
+
+// useProtocol sets the protocol for the sequence message which is sent after a reconnect
+func (r *resumableConnection) useProtocol(protocol HubProtocol) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.protocol = protocol
+}
+
+func (r *resumableConnection) SetTimeout(timeout time.Duration) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.timeout = timeout
+	if r.transport != nil {
+		r.transport.conn.SetTimeout(timeout)
+	}
+}
+
+func (r *resumableConnection) Timeout() time.Duration {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.timeout
+}
+
+// RequestFeatures returns the RequestFeatures of the last attached transport
+func (r *resumableConnection) RequestFeatures() *RequestFeatures {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.baseConnection.RequestFeatures()
+}
+
+func (r *resumableConnection) setReadLimit(limit int64) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.readLimit = limit
+	if r.transport != nil {
+		if limiter, ok := r.transport.conn.(readLimiter); ok {
+			limiter.setReadLimit(limit)
+		}
+	}
+}
+
+func (r *resumableConnection) setCloseCause(cause error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.transport != nil {
+		if causer, ok := r.transport.conn.(closeCauser); ok {
+			causer.setCloseCause(cause)
+		}
+	}
+}
+
+func (r *resumableConnection) setTransferFormat(format string) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.transferFormat = format
+	if r.transport != nil {
+		if formatter, ok := r.transport.conn.(transferFormatter); ok {
+			return formatter.setTransferFormat(format)
+		}
+	}
+	return nil
+}
+
+// isSequenced returns if the message is counted and acknowledged by stateful reconnect
+func isSequenced(message interface{}) bool {
+	switch message.(type) {
+	case invocationMessage, streamItemMessage, completionMessage, cancelInvocationMessage, *preparedMessage:
+		return true
+	default:
+		return false
+	}
+}
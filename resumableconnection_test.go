@@ -0,0 +1,221 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordingConnection records the written messages. If release is set, writes wait until it is closed.
+type recordingConnection struct {
+	baseConnection
+	release  chan struct{}
+	writing  chan struct{}
+	once     sync.Once
+	mx       sync.Mutex
+	messages []string
+}
+
+func newRecordingConnection(release chan struct{}) *recordingConnection {
+	return &recordingConnection{
+		baseConnection: baseConnection{ctx: context.Background(), connectionID: "recording"},
+		release:        release,
+		writing:        make(chan struct{}),
+	}
+}
+
+func (c *recordingConnection) Read([]byte) (int, error) {
+	<-c.ctx.Done()
+	return 0, c.ctx.Err()
+}
+
+func (c *recordingConnection) Write(p []byte) (int, error) {
+	c.once.Do(func() { close(c.writing) })
+	if c.release != nil {
+		<-c.release
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.messages = append(c.messages, string(p))
+	return len(p), nil
+}
+
+func (c *recordingConnection) written() []string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]string(nil), c.messages...)
+}
+
+var _ = Describe("Stateful reconnect", func() {
+	Context("When messages are sent and acknowledged", func() {
+		It("should keep the unacknowledged messages and wait for space when the buffer is full", func(done Done) {
+			buffer := newMessageBuffer(10)
+			Expect(buffer.waitForSpace(context.TODO(), 20)).To(Succeed())
+			buffer.add([]byte("0123456789"))
+			buffer.add([]byte("abcde"))
+			buffer.add([]byte("fghij"))
+			first, messages := buffer.resend()
+			Expect(first).To(Equal(uint64(1)))
+			Expect(messages).To(HaveLen(3))
+			waited := make(chan error, 1)
+			go func() { waited <- buffer.waitForSpace(context.TODO(), 5) }()
+			Consistently(waited, 0.1).ShouldNot(Receive())
+			buffer.ack(2)
+			Eventually(waited).Should(Receive(BeNil()))
+			first, messages = buffer.resend()
+			Expect(first).To(Equal(uint64(3)))
+			Expect(messages).To(Equal([][]byte{[]byte("fghij")}))
+			messages, next := buffer.since(2)
+			Expect(messages).To(Equal([][]byte{[]byte("fghij")}))
+			Expect(next).To(Equal(uint64(4)))
+			buffer.ack(3)
+			first, messages = buffer.resend()
+			Expect(first).To(Equal(uint64(4)))
+			Expect(messages).To(BeEmpty())
+			close(done)
+		}, 2.0)
+	})
+	Context("When messages are received and resent after a reconnect", func() {
+		It("should skip the messages received before", func() {
+			buffer := newMessageBuffer(10)
+			Expect(buffer.receive()).To(BeTrue())
+			Expect(buffer.receive()).To(BeTrue())
+			id, ok := buffer.pendingAck()
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(uint64(2)))
+			_, ok = buffer.pendingAck()
+			Expect(ok).To(BeFalse())
+			buffer.disconnected()
+			// Messages before the sequence message can not be counted
+			Expect(buffer.receive()).To(BeFalse())
+			Expect(buffer.sequence(2)).To(Succeed())
+			Expect(buffer.receive()).To(BeFalse())
+			Expect(buffer.receive()).To(BeTrue())
+			// The ack might have been lost with the transport
+			id, ok = buffer.pendingAck()
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(uint64(3)))
+			Expect(buffer.sequence(5)).NotTo(Succeed())
+		})
+	})
+	Context("When a slow transport is attached", func() {
+		It("should resend the messages without blocking the connection", func(done Done) {
+			resumable := newResumableConnection(context.Background(), "slow",
+				&statefulReconnect{enabled: true, bufferSize: 1 << 16, timeout: time.Second}, nil)
+			protocol := &JSONHubProtocol{}
+			protocol.setDebugLogger(log.NewNopLogger())
+			resumable.useProtocol(protocol)
+			previous := newRecordingConnection(nil)
+			_, err := resumable.attach(previous)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resumable.writeSequenced(context.TODO(), []byte("1"))).To(Succeed())
+			release := make(chan struct{})
+			slow := newRecordingConnection(release)
+			attached := make(chan error, 1)
+			go func() {
+				_, err := resumable.attach(slow)
+				attached <- err
+			}()
+			<-slow.writing
+			// The previous transport is used until the resent messages are written
+			transport, err := resumable.current(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(transport.conn).To(BeIdenticalTo(previous))
+			Expect(resumable.writeSequenced(context.TODO(), []byte("2"))).To(Succeed())
+			close(release)
+			Expect(<-attached).To(Succeed())
+			Expect(slow.written()).To(Equal([]string{"{\"type\":9,\"sequenceId\":1}\u001e", "1", "2"}))
+			transport, err = resumable.current(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(transport.conn).To(BeIdenticalTo(slow))
+			close(done)
+		}, 2.0)
+	})
+	Context("When a server and a client use stateful reconnect", func() {
+		var httpServer *httptest.Server
+		var cancel context.CancelFunc
+		start := func(options ...func(Party) error) Server {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			server, err := NewServer(ctx, append([]func(Party) error{SimpleHubFactory(&addHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+			Expect(err).NotTo(HaveOccurred())
+			httpServer = httptest.NewServer(server.Handler())
+			return server
+		}
+		AfterEach(func() {
+			cancel()
+			httpServer.Close()
+		})
+		It("should negotiate stateful reconnect only when the client asks for it", func() {
+			start(StatefulReconnect(1<<16, time.Second))
+			Expect(negotiateTestServer(httpServer.URL, "?useStatefulReconnect=true").UseStatefulReconnect).To(BeTrue())
+			Expect(negotiateTestServer(httpServer.URL, "").UseStatefulReconnect).To(BeFalse())
+		})
+		It("should not negotiate stateful reconnect when the server does not allow it", func() {
+			start()
+			Expect(negotiateTestServer(httpServer.URL, "?useStatefulReconnect=true").UseStatefulReconnect).To(BeFalse())
+		})
+		It("should resume the connection after the transport is lost and resend the lost messages", func(done Done) {
+			versions := make(chan int, 1)
+			server := start(StatefulReconnect(1<<16, 2*time.Second), AcceptHandshake(func(handshake Handshake) error {
+				versions <- handshake.Version
+				return nil
+			}))
+			Expect(server.Handle("remember", func(ctx HubContext, value string) string {
+				ctx.Items().Store("value", value)
+				return value
+			})).To(Succeed())
+			Expect(server.Handle("recall", func(ctx HubContext) string {
+				value, _ := ctx.Items().Load("value")
+				return value.(string)
+			})).To(Succeed())
+			Expect(server.Handle("delayedAdd2", func(i int) int {
+				time.Sleep(200 * time.Millisecond)
+				return i + 2
+			})).To(Succeed())
+			httpClient, err := NewHTTPClient(context.TODO(), httpServer.URL, StatefulReconnect(1<<16, 2*time.Second),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(httpClient.Start()).To(Succeed())
+			// Ack and Sequence messages need protocol version 2
+			Expect(<-versions).To(Equal(2))
+			Expect(<-httpClient.Invoke("remember", "kept")).To(Equal(InvokeResult{Value: "kept"}))
+			// The completion of delayedAdd2 is sent while the transport is lost
+			delayed := httpClient.Invoke("delayedAdd2", 1)
+			resumable := httpClient.(*client).conn.(*resumableConnection)
+			transport, err := resumable.current(false)
+			Expect(err).NotTo(HaveOccurred())
+			// Close waits for the close handshake, which can take long on a busy machine
+			go func(ws *websocket.Conn) { _ = ws.Close(websocket.StatusGoingAway, "") }(transport.conn.(*webSocketConnection).conn)
+			// The invocation is sent while the transport is closing or lost
+			added := httpClient.Invoke("add2", 2)
+			Expect(<-delayed).To(Equal(InvokeResult{Value: json.Number("3")}))
+			Expect(<-added).To(Equal(InvokeResult{Value: json.Number("4")}))
+			Expect(<-httpClient.Invoke("recall")).To(Equal(InvokeResult{Value: "kept"}))
+			Expect(httpClient.Stop()).To(Succeed())
+			close(done)
+		}, 10.0)
+		It("should end the connection when the transport is not replaced within the timeout", func(done Done) {
+			start(StatefulReconnect(1<<16, 100*time.Millisecond))
+			nr := negotiateTestServer(httpServer.URL, "?negotiateVersion=1&useStatefulReconnect=true")
+			ws := dialHandshake(httpServer.URL + "?id=" + nr.ConnectionToken)
+			_, _, err := ws.Read(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Close(websocket.StatusGoingAway, "")).To(Succeed())
+			// After the timeout, the connection token is unknown
+			time.Sleep(500 * time.Millisecond)
+			_, resp, err := websocket.Dial(context.TODO(), httpServer.URL+"?id="+nr.ConnectionToken, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(404))
+			close(done)
+		}, 3.0)
+	})
+})
@@ -8,23 +8,51 @@ package signalr
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-kit/kit/log"
-	"github.com/mailru/easyjson/jwriter"
+	"net"
 	"net/http"
 	"os"
 	"reflect"
 	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Server is a SignalR server for one type of hub
 type Server interface {
 	Party
 	ServeHTTP(path string) *http.ServeMux
+	// Handler returns the http.Handler which serves negotiate, connect and send requests of all transports.
+	// It works relative to the request path: requests to a path ending with "/negotiate" are negotiate requests,
+	// all others are connect or send requests. So the Handler can be mounted on any router, e.g. behind http.StripPrefix.
+	// All calls return the same Handler.
+	Handler() http.Handler
 	ServeConnection(conn Connection)
+	// ListenAndServeTCP listens on the TCP network address and serves the connections until the server context is done
+	ListenAndServeTCP(address string) error
+	// ServeListener accepts connections on listener and serves them until the server context is done.
+	// listener can be any net.Listener, e.g. from tls.Listen or from net.Listen with network "unix".
+	// Other errors of Accept are retried with a growing delay up to one second, as long as listener is not closed.
+	// The returned error is nil when the server context is done, otherwise the error of Accept on the closed listener.
+	ServeListener(listener net.Listener) error
+	// Handle registers handler as hub method with the given name. handler must be a func. If its first parameter
+	// is of type HubContext, it receives the HubContext of the calling connection, all other parameters are sent
+	// by the client. A method with the same name, either hub method or handler, is replaced.
+	// Handle can be called while the server is running.
+	Handle(method string, handler interface{}) error
+	// RemoveHandler removes the hub method or handler with the given name, so clients can no longer invoke it
+	RemoveHandler(method string)
 	availableTransports() []string
+	cors() *corsPolicy
+	connectionTokenKey() []byte
+	negotiation() *negotiationPolicy
+	admission() *admissionPolicy
+	webSocket() *webSocketPolicy
 }
 
 type server struct {
@@ -35,6 +63,20 @@ type server struct {
 	groupManager      GroupManager
 	reconnectAllowed  bool
 	transports        []string
+	corsPolicy        corsPolicy
+	tokenKey          []byte
+	negotiationPolicy negotiationPolicy
+	admissionPolicy   admissionPolicy
+	webSocketPolicy   webSocketPolicy
+	methods           *methodTable
+	methodAliases     map[string]string
+	hiddenMethods     []string
+	handlerOnce       sync.Once
+	handler           http.Handler
+
+	lifecyclePanicPolicy   PanicPolicy
+	lifecyclePanicReporter func(connectionID string, method string, err interface{}, stack []byte)
+	handshakeHook          func(handshake Handshake) error
 }
 
 // NewServer creates a new server for one type of hub. The hub type is set by one of the
@@ -53,6 +95,14 @@ func NewServer(ctx context.Context, options ...func(Party) error) (Server, error
 		},
 		partyBase:        newPartyBase(ctx, info, dbg),
 		reconnectAllowed: true,
+		negotiationPolicy: negotiationPolicy{
+			timeout:             15 * time.Second,
+			maxPendingPerClient: 100,
+		},
+		admissionPolicy: admissionPolicy{
+			perAddress: make(map[string]int),
+			perUser:    make(map[string]int),
+		},
 	}
 	for _, option := range options {
 		if option != nil {
@@ -61,49 +111,201 @@ func NewServer(ctx context.Context, options ...func(Party) error) (Server, error
 			}
 		}
 	}
+	if err := server.corsPolicy.validate(); err != nil {
+		return nil, err
+	}
+	if server.tokenKey == nil {
+		key, err := newConnectionTokenKey()
+		if err != nil {
+			return nil, err
+		}
+		server.tokenKey = key
+	}
 	if server.transports == nil {
 		server.transports = []string{"WebSockets", "ServerSentEvents"}
 	}
 	if server.newHub == nil {
 		return server, errors.New("cannot determine hub type. Neither UseHub, HubFactory or SimpleHubFactory given as option")
 	}
+	// Hidden methods are not validated, as clients can not invoke them, unless they have an alias
+	excluded := append([]string{}, hubBaseMethods...)
+	for _, method := range server.hiddenMethods {
+		if !server.isAliased(method) {
+			excluded = append(excluded, method)
+		}
+	}
+	methods, err := newMethodTable(reflect.TypeOf(server.newHub()), excluded...)
+	if err != nil {
+		return nil, err
+	}
+	// Aliases first, so renaming is possible by hiding the original name
+	for alias, method := range server.methodAliases {
+		if err = methods.alias(alias, method); err != nil {
+			return nil, err
+		}
+	}
+	for _, method := range server.hiddenMethods {
+		methods.remove(method)
+	}
+	server.methods = methods
 	return server, nil
 }
 
+func (s *server) isAliased(method string) bool {
+	for _, aliased := range s.methodAliases {
+		if strings.EqualFold(aliased, method) {
+			return true
+		}
+	}
+	return false
+}
+
 // MapHub maps the hub to a path and returns the http.ServerMux which handles it
 func (s *server) ServeHTTP(path string) *http.ServeMux {
-	httpMux := newHTTPMux(s)
 	mux := http.NewServeMux()
-	mux.HandleFunc(fmt.Sprintf("%s/negotiate", path), httpMux.negotiate)
-	mux.Handle(path, httpMux)
+	mapHTTP(mux, path, s)
 	return mux
 }
 
-// ServeConnection serves one connection. The same server might serve different connections in parallel
+func (s *server) Handler() http.Handler {
+	s.handlerOnce.Do(func() {
+		s.handler = &httpHandler{httpMux: newHTTPMux(s)}
+	})
+	return s.handler
+}
+
+// ServeConnection serves one connection. The same server might serve different connections in parallel.
+// Connections which exceed the admission limits are rejected with an error handshake response.
 func (s *server) ServeConnection(conn Connection) {
-	if protocol, err := s.processHandshake(conn); err != nil {
+	request := emptyRequestFeatures
+	if c, ok := conn.(ConnectionWithRequestFeatures); ok {
+		request = c.RequestFeatures()
+	}
+	release, admissionErr := s.admissionPolicy.acquire(request)
+	defer release()
+	if protocol, version, received, err := s.processHandshake(conn, admissionErr); err != nil {
 		info, _ := s.prefixLoggers("")
 		_ = info.Log(evt, "processHandshake", "connectionId", conn.ConnectionID(), "error", err, react, "do not connect")
+		if causer, ok := conn.(closeCauser); ok {
+			causer.setCloseCause(&handshakeError{err})
+		}
 	} else {
-		newLoop(s, conn, protocol).Run(make(chan struct{}, 1))
+		if resumable, ok := conn.(*resumableConnection); ok && version < statefulReconnectVersion {
+			// Without Ack and Sequence messages, the connection can not be resumed
+			resumable.disable()
+		}
+		newLoop(s, conn, protocol, received).Run(make(chan struct{}, 1))
+	}
+}
+
+func (s *server) ListenAndServeTCP(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.ServeListener(listener)
+}
+
+func (s *server) ServeListener(listener net.Listener) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-s.context().Done():
+			_ = listener.Close()
+		case <-stop:
+		}
+	}()
+	var retryDelay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.context().Err() != nil {
+				return nil
+			}
+			if isClosedListenerError(err) {
+				return err
+			}
+			// Back off on other errors like running out of file descriptors, as long as the listener is open
+			if retryDelay = 2 * retryDelay; retryDelay == 0 {
+				retryDelay = 5 * time.Millisecond
+			} else if retryDelay > time.Second {
+				retryDelay = time.Second
+			}
+			info, _ := s.loggers()
+			_ = info.Log(evt, "accept", "error", err, react, fmt.Sprintf("retry in %v", retryDelay))
+			select {
+			case <-time.After(retryDelay):
+			case <-s.context().Done():
+				return nil
+			}
+			continue
+		}
+		retryDelay = 0
+		go s.serveNetConn(conn)
+	}
+}
+
+// isClosedListenerError reports if err is returned by Accept of a closed listener.
+// net.ErrClosed needs Go 1.16, so the text of the error is checked
+func isClosedListenerError(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// serveNetConn serves conn and closes it when the connection has ended
+func (s *server) serveNetConn(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Complete the TLS handshake, so the RequestFeatures know the TLS state
+		_ = tlsConn.SetDeadline(time.Now().Add(s._handshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			info, _ := s.prefixLoggers("")
+			_ = info.Log(evt, "tls handshake", "remoteAddr", conn.RemoteAddr(), "error", err, react, "do not connect")
+			_ = conn.Close()
+			return
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
 	}
+	ctx, cancel := context.WithCancel(s.context())
+	// Closes conn
+	defer cancel()
+	s.ServeConnection(newNetConnection(ctx, newConnectionID(), conn))
 }
 
 func (s *server) availableTransports() []string {
 	return s.transports
 }
 
+func (s *server) connectionTokenKey() []byte {
+	return s.tokenKey
+}
+
+func (s *server) cors() *corsPolicy {
+	return &s.corsPolicy
+}
+
+func (s *server) negotiation() *negotiationPolicy {
+	return &s.negotiationPolicy
+}
+
+func (s *server) admission() *admissionPolicy {
+	return &s.admissionPolicy
+}
+
+func (s *server) webSocket() *webSocketPolicy {
+	return &s.webSocketPolicy
+}
+
 func (s *server) onConnected(hc hubConnection) {
 	s.lifetimeManager.OnConnected(hc)
 	go func() {
-		defer s.recoverHubLifeCyclePanic()
+		defer s.recoverHubLifeCyclePanic(hc, "OnConnected")
 		s.invocationTarget(hc).(HubInterface).OnConnected(hc.ConnectionID())
 	}()
 }
 
 func (s *server) onDisconnected(hc hubConnection) {
 	go func() {
-		defer s.recoverHubLifeCyclePanic()
+		defer s.recoverHubLifeCyclePanic(hc, "OnDisconnected")
 		s.invocationTarget(hc).(HubInterface).OnDisconnected(hc.ConnectionID())
 	}()
 	s.lifetimeManager.OnDisconnected(hc)
@@ -116,17 +318,48 @@ func (s *server) invocationTarget(conn hubConnection) interface{} {
 	return hub
 }
 
+func (s *server) Handle(method string, handler interface{}) error {
+	hubMethod, err := newHandlerMethod(method, handler)
+	if err != nil {
+		return err
+	}
+	s.methods.set(method, hubMethod)
+	return nil
+}
+
+func (s *server) RemoveHandler(method string) {
+	s.methods.remove(method)
+}
+
+func (s *server) invocationMethod(name string) (*hubMethod, bool) {
+	return s.methods.method(name)
+}
+
 func (s *server) allowReconnect() bool {
 	return s.reconnectAllowed
 }
 
-func (s *server) recoverHubLifeCyclePanic() {
+func (s *server) recoverHubLifeCyclePanic(hc hubConnection, method string) {
 	if err := recover(); err != nil {
-		s.reconnectAllowed = false
-		info, dbg := s.prefixLoggers("")
-		_ = info.Log(evt, "panic in hub lifecycle", "error", err, react, "close connection, allow no reconnect")
-		_ = dbg.Log(evt, "panic in hub lifecycle", "error", err, react, "close connection, allow no reconnect", "stack", string(debug.Stack()))
-		s.cancel()
+		stack := debug.Stack()
+		if s.lifecyclePanicReporter != nil {
+			s.lifecyclePanicReporter(hc.ConnectionID(), method, err, stack)
+		}
+		info, dbg := s.prefixLoggers(hc.ConnectionID())
+		switch s.lifecyclePanicPolicy {
+		case PanicIgnore:
+			_ = info.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "ignore")
+			_ = dbg.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "ignore", "stack", string(stack))
+		case PanicCrash:
+			s.reconnectAllowed = false
+			_ = info.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "stop server, allow no reconnect")
+			_ = dbg.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "stop server, allow no reconnect", "stack", string(stack))
+			s.cancel()
+		default:
+			_ = info.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "close connection")
+			_ = dbg.Log(evt, "panic in hub lifecycle", "method", method, "error", err, react, "close connection", "stack", string(stack))
+			hc.AbortWithError(fmt.Errorf("panic in %s: %v", method, err))
+		}
 	}
 }
 
@@ -155,12 +388,13 @@ func (s *server) newConnectionHubContext(hubConn hubConnection) HubContext {
 	}
 }
 
-func (s *server) processHandshake(conn Connection) (HubProtocol, error) {
+// processHandshake reads the handshake request and sends the handshake response.
+// It returns the negotiated protocol and version and the data received after the handshake request.
+// If admissionErr is not nil, the connection is rejected with it.
+func (s *server) processHandshake(conn Connection, admissionErr error) (HubProtocol, int, []byte, error) {
 	var err error
 	var protocol HubProtocol
-	var ok bool
 	const handshakeResponse = "{}\u001e"
-	const errorHandshakeResponse = "{\"error\":\"%s\"}\u001e"
 	info, dbg := s.prefixLoggers(conn.ConnectionID())
 
 	defer conn.SetTimeout(0)
@@ -176,39 +410,84 @@ func (s *server) processHandshake(conn Connection) (HubProtocol, error) {
 			buf.Write(data[:n])
 			var rawHandshake []byte
 			if rawHandshake, err = parseTextMessageFormat(&buf); err != nil {
-				// Partial message, read more data
-				buf.Write(data[:n])
-			} else {
-				_ = dbg.Log(evt, "handshake received", "msg", string(rawHandshake))
-				request := handshakeRequest{}
-				if err = json.Unmarshal(rawHandshake, &request); err != nil {
-					// Malformed handshake
+				if uint(buf.Len()) > s.maximumReceiveMessageSize() {
+					err = &messageTooLargeError{size: uint(buf.Len()), maximumSize: s.maximumReceiveMessageSize()}
 					break
 				}
-				if protocol, ok = protocolMap[request.Protocol]; ok {
-					// Send the handshake response
-					if _, err = conn.Write([]byte(handshakeResponse)); err != nil {
-						_ = dbg.Log(evt, "handshake sent", "error", err)
-					} else {
-						_ = dbg.Log(evt, "handshake sent", "msg", handshakeResponse)
-					}
-				} else {
-					err = fmt.Errorf("protocol %v not supported", request.Protocol)
-					_ = info.Log(evt, "protocol requested", "error", err)
-					if _, respErr := conn.Write([]byte(fmt.Sprintf(errorHandshakeResponse, err))); respErr != nil {
-						_ = dbg.Log(evt, "handshake sent", "error", respErr)
-						err = respErr
-					}
+				// Partial message, read more data
+				continue
+			}
+			_ = dbg.Log(evt, "handshake received", "msg", string(rawHandshake))
+			request := handshakeRequest{}
+			if err = json.Unmarshal(rawHandshake, &request); err != nil {
+				// Malformed handshake
+				break
+			}
+			if admissionErr != nil {
+				err = admissionErr
+				_ = info.Log(evt, "connection admission", "error", err)
+			} else if protocol, err = negotiateProtocol(conn, request, *s.protocols()); err != nil {
+				_ = info.Log(evt, "protocol requested", "protocol", request.Protocol, "version", request.Version, "error", err)
+			} else if err = s.acceptHandshake(conn, request); err != nil {
+				_ = info.Log(evt, "handshake rejected", "protocol", request.Protocol, "version", request.Version, "error", err)
+			}
+			if err != nil {
+				if respErr := writeHandshakeError(conn, err); respErr != nil {
+					_ = dbg.Log(evt, "handshake sent", "error", respErr)
+					err = respErr
 				}
 				break
 			}
+			// Send the handshake response
+			if _, err = conn.Write([]byte(handshakeResponse)); err != nil {
+				_ = dbg.Log(evt, "handshake sent", "error", err)
+			} else {
+				_ = dbg.Log(evt, "handshake sent", "msg", handshakeResponse)
+			}
+			return protocol, request.Version, buf.Bytes(), err
 		}
 	}
-	return protocol, err
+	return nil, 0, buf.Bytes(), err
+}
+
+// handshakeError is the reason for closing a connection whose handshake failed or was rejected
+type handshakeError struct {
+	err error
+}
+
+func (h *handshakeError) Error() string {
+	return h.err.Error()
+}
+
+func (h *handshakeError) Unwrap() error {
+	return h.err
+}
+
+// acceptHandshake calls the handshake hook set by AcceptHandshake
+func (s *server) acceptHandshake(conn Connection, request handshakeRequest) error {
+	if s.handshakeHook == nil {
+		return nil
+	}
+	handshake := Handshake{
+		ConnectionID:    conn.ConnectionID(),
+		Protocol:        request.Protocol,
+		Version:         request.Version,
+		RequestFeatures: emptyRequestFeatures,
+	}
+	if c, ok := conn.(ConnectionWithRequestFeatures); ok {
+		handshake.RequestFeatures = c.RequestFeatures()
+	}
+	return s.handshakeHook(handshake)
 }
 
-var protocolMap = map[string]HubProtocol{
-	"json": &JSONHubProtocol{easyWriter: jwriter.Writer{}},
+// writeHandshakeError sends the handshake response which rejects the connection with err
+func writeHandshakeError(conn Connection, err error) error {
+	response, mErr := json.Marshal(handshakeResponse{Error: err.Error()})
+	if mErr != nil {
+		return mErr
+	}
+	_, wErr := conn.Write(append(response, 30))
+	return wErr
 }
 
 // const for logging
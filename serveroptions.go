@@ -3,7 +3,11 @@ package signalr
 import (
 	"errors"
 	"fmt"
+	"github.com/go-kit/kit/metrics"
+	"path"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // UseHub sets the hub instance used by the server
@@ -45,6 +49,8 @@ func SimpleHubFactory(hubProto HubInterface) func(Party) error {
 func HTTPTransports(transports ...string) func(Party) error {
 	return func(p Party) error {
 		if s, ok := p.(*server); ok {
+			// Replace the transports set before, e.g. by the shared options of a HubRouter
+			s.transports = nil
 			for _, transport := range transports {
 				switch transport {
 				case "WebSockets", "ServerSentEvents":
@@ -59,3 +65,265 @@ func HTTPTransports(transports ...string) func(Party) error {
 		return errors.New("option Transports is server only")
 	}
 }
+
+// PanicPolicy defines how the server reacts to a panic in the OnConnected or OnDisconnected method of a hub
+type PanicPolicy int
+
+const (
+	// PanicCloseConnection closes the connection on which the panic occurred. The client receives a close message with error.
+	PanicCloseConnection PanicPolicy = iota
+	// PanicIgnore logs the panic and keeps the connection open
+	PanicIgnore
+	// PanicCrash stops the server and closes all its connections. Clients are not allowed to reconnect.
+	PanicCrash
+)
+
+// HubLifecyclePanicPolicy sets how the server reacts to a panic in OnConnected or OnDisconnected of a hub.
+// Default is PanicCloseConnection.
+func HubLifecyclePanicPolicy(policy PanicPolicy) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			switch policy {
+			case PanicCloseConnection, PanicIgnore, PanicCrash:
+				s.lifecyclePanicPolicy = policy
+				return nil
+			default:
+				return fmt.Errorf("unsupported PanicPolicy: %v", policy)
+			}
+		}
+		return errors.New("option HubLifecyclePanicPolicy is server only")
+	}
+}
+
+// HubLifecyclePanicReporter sets a function which is called when OnConnected or OnDisconnected of a hub panics.
+// The reporter is called before the PanicPolicy is applied. method is either "OnConnected" or "OnDisconnected",
+// err is the value passed to panic and stack is the stack trace of the panicking goroutine.
+func HubLifecyclePanicReporter(reporter func(connectionID string, method string, err interface{}, stack []byte)) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.lifecyclePanicReporter = reporter
+			return nil
+		}
+		return errors.New("option HubLifecyclePanicReporter is server only")
+	}
+}
+
+// HubMethodAlias makes the hub method available under the name alias, e.g. to match the method names
+// used by existing clients. To rename a method, combine it with HideHubMethods for the original name.
+func HubMethodAlias(alias string, method string) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if s.methodAliases == nil {
+				s.methodAliases = make(map[string]string)
+			}
+			s.methodAliases[alias] = method
+			return nil
+		}
+		return errors.New("option HubMethodAlias is server only")
+	}
+}
+
+// HideHubMethods prevents that clients can invoke the exported hub methods with the given names.
+// The methods of the embedded Hub, like Clients, Groups, Items or Logger, are always hidden.
+// Hidden methods may have signatures which can not be invoked, e.g. func parameters.
+func HideHubMethods(methods ...string) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.hiddenMethods = append(s.hiddenMethods, methods...)
+			return nil
+		}
+		return errors.New("option HideHubMethods is server only")
+	}
+}
+
+// AllowOriginPatterns sets the origins from which browsers may connect. It applies to negotiate and send requests
+// and to the connect requests of all transports. Requests from other origins are rejected with 403 Forbidden.
+// Allowed requests get the CORS headers, preflight requests are answered.
+// A pattern is either "*" for any origin or a pattern as in path.Match, e.g. "https://*.example.com".
+// Requests without Origin header are not sent by browsers and are always allowed.
+// Default is that all origins are allowed and no CORS headers are sent.
+func AllowOriginPatterns(patterns ...string) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			// Replace the patterns set before, e.g. by the shared options of a HubRouter
+			s.corsPolicy.originPatterns = nil
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("invalid origin pattern %v: %w", pattern, err)
+				}
+				s.corsPolicy.originPatterns = append(s.corsPolicy.originPatterns, strings.ToLower(pattern))
+			}
+			return nil
+		}
+		return errors.New("option AllowOriginPatterns is server only")
+	}
+}
+
+// AllowCredentials allows browsers to send credentials like cookies with requests from the origins
+// set by AllowOriginPatterns. It can not be used with the origin pattern "*", NewServer fails then.
+func AllowCredentials() func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.corsPolicy.allowCredentials = true
+			return nil
+		}
+		return errors.New("option AllowCredentials is server only")
+	}
+}
+
+// ConnectionTokenKey sets the key for signing the connectionTokens, which clients use to connect and send after
+// negotiation. Servers with the same key accept the connectionTokens of each other. key must have at least 16 bytes.
+// Default is a random key per server.
+func ConnectionTokenKey(key []byte) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if len(key) < 16 {
+				return errors.New("connectionTokenKey must have at least 16 bytes")
+			}
+			s.tokenKey = append([]byte(nil), key...)
+			return nil
+		}
+		return errors.New("option ConnectionTokenKey is server only")
+	}
+}
+
+// NegotiateTimeout sets the time in which a client must connect after negotiation. Negotiated connections
+// which are not connected in time are removed and their connectionToken is no longer accepted.
+// Default is 15 seconds.
+func NegotiateTimeout(timeout time.Duration) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if timeout <= 0 {
+				return errors.New("negotiateTimeout must be greater than 0")
+			}
+			s.negotiationPolicy.timeout = timeout
+			return nil
+		}
+		return errors.New("option NegotiateTimeout is server only")
+	}
+}
+
+// MaxPendingNegotiations sets the maximum number of connections a client, identified by its remote address,
+// can have negotiated but not yet connected. Further negotiate requests are answered with 429 Too Many Requests.
+// 0 means no limit. Default is 100.
+func MaxPendingNegotiations(max int) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if max < 0 {
+				return errors.New("maxPendingNegotiations must not be negative")
+			}
+			s.negotiationPolicy.maxPendingPerClient = max
+			return nil
+		}
+		return errors.New("option MaxPendingNegotiations is server only")
+	}
+}
+
+// NegotiatedConnectionsGauge sets a gauge which is set to the number of http connections the server keeps track of,
+// negotiated ones and connected ones.
+// See github.com/go-kit/kit/metrics for adapters to common metrics systems.
+func NegotiatedConnectionsGauge(gauge metrics.Gauge) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.negotiationPolicy.gauge = gauge
+			return nil
+		}
+		return errors.New("option NegotiatedConnectionsGauge is server only")
+	}
+}
+
+// WebSocketPingInterval sets the interval in which the server sends WebSocket ping frames to connected clients.
+// If a client does not answer a ping with a pong frame within the interval, its connection is closed.
+// This detects broken WebSocket connections independently of the SignalR KeepAliveInterval.
+// 0 means no ping frames are sent. Default is 0.
+func WebSocketPingInterval(interval time.Duration) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if interval < 0 {
+				return errors.New("webSocketPingInterval must not be negative")
+			}
+			s.webSocketPolicy.pingInterval = interval
+			return nil
+		}
+		return errors.New("option WebSocketPingInterval is server only")
+	}
+}
+
+// MaxConnections sets the maximum number of connections the server serves at the same time.
+// Further connections are rejected, over http with 503 Service Unavailable.
+// 0 means no limit, which is the default.
+func MaxConnections(max int) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if max < 0 {
+				return errors.New("maxConnections must not be negative")
+			}
+			s.admissionPolicy.maxConnections = max
+			return nil
+		}
+		return errors.New("option MaxConnections is server only")
+	}
+}
+
+// MaxConnectionsPerAddress sets the maximum number of connections the server serves at the same time for one
+// remote address. Further connections are rejected, over http with 429 Too Many Requests.
+// 0 means no limit, which is the default.
+func MaxConnectionsPerAddress(max int) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if max < 0 {
+				return errors.New("maxConnectionsPerAddress must not be negative")
+			}
+			s.admissionPolicy.maxPerAddress = max
+			return nil
+		}
+		return errors.New("option MaxConnectionsPerAddress is server only")
+	}
+}
+
+// MaxConnectionsPerUser sets the maximum number of connections the server serves at the same time for one user.
+// userID returns the user of a request, e.g. from its authorization header. Requests for which it returns ""
+// are not limited. Further connections are rejected, over http with 429 Too Many Requests.
+// 0 means no limit, which is the default.
+func MaxConnectionsPerUser(max int, userID func(request *RequestFeatures) string) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			if max < 0 {
+				return errors.New("maxConnectionsPerUser must not be negative")
+			}
+			if userID == nil {
+				return errors.New("userID must not be nil")
+			}
+			s.admissionPolicy.maxPerUser = max
+			s.admissionPolicy.userID = userID
+			return nil
+		}
+		return errors.New("option MaxConnectionsPerUser is server only")
+	}
+}
+
+// Admit sets a function which decides if the server accepts a connection for request. If it returns an error,
+// the connection is rejected, over http with 403 Forbidden. For http connections, admit is called
+// for the negotiate request, the connect request and when the connection starts.
+func Admit(admit func(request *RequestFeatures) error) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.admissionPolicy.admit = admit
+			return nil
+		}
+		return errors.New("option Admit is server only")
+	}
+}
+
+// AcceptHandshake sets a function which is called when the server received the handshake request of a connection
+// with a supported protocol and version. If it returns an error, the connection is rejected and the error is sent
+// to the client in the handshake response.
+func AcceptHandshake(accept func(handshake Handshake) error) func(Party) error {
+	return func(p Party) error {
+		if s, ok := p.(*server); ok {
+			s.handshakeHook = accept
+			return nil
+		}
+		return errors.New("option AcceptHandshake is server only")
+	}
+}
@@ -36,6 +36,14 @@ func (s *singleHub) initUUID() {
 
 var singleHubMsg = make(chan string, 100)
 
+type lifecyclePanicHub struct {
+	invocationHub
+}
+
+func (l *lifecyclePanicHub) OnConnected(string) {
+	panic("Don't panic in OnConnected!")
+}
+
 var _ = Describe("Server options", func() {
 
 	Describe("UseHub option", func() {
@@ -342,6 +350,75 @@ var _ = Describe("Server options", func() {
 			})
 		})
 	})
+	Describe("HubLifecyclePanicPolicy option", func() {
+		Context("When OnConnected panics and no HubLifecyclePanicPolicy is set", func() {
+			It("should close only the affected connection with an error", func(done Done) {
+				server, err := NewServer(context.TODO(), SimpleHubFactory(&lifecyclePanicHub{}))
+				Expect(err).NotTo(HaveOccurred())
+				conn1 := newTestingConnectionForServer()
+				go server.ServeConnection(conn1)
+				select {
+				case m := <-conn1.ReceiveChan():
+					Expect(m).To(BeAssignableToTypeOf(closeMessage{}))
+					Expect(m.(closeMessage).Error).To(ContainSubstring("panic in OnConnected"))
+				case <-time.After(500 * time.Millisecond):
+					Fail("timed out")
+				}
+				// The server should still serve other connections
+				conn2 := newTestingConnectionForServer()
+				go server.ServeConnection(conn2)
+				<-conn2.ReceiveChan()
+				Expect(server.context().Err()).To(BeNil())
+				close(done)
+			}, 2.0)
+		})
+		Context("When OnConnected panics and the PanicIgnore policy is set", func() {
+			It("should keep the connection open and report the panic", func(done Done) {
+				reported := make(chan string, 1)
+				server, err := NewServer(context.TODO(), SimpleHubFactory(&lifecyclePanicHub{}),
+					HubLifecyclePanicPolicy(PanicIgnore),
+					HubLifecyclePanicReporter(func(connectionID string, method string, err interface{}, stack []byte) {
+						reported <- method
+					}))
+				Expect(err).NotTo(HaveOccurred())
+				conn := newTestingConnectionForServer()
+				go server.ServeConnection(conn)
+				Expect(<-reported).To(Equal("OnConnected"))
+				conn.ClientSend(`{"type":1,"invocationId": "123","target":"simple"}`)
+				Expect(<-invocationQueue).To(Equal("Simple()"))
+				select {
+				case m := <-conn.ReceiveChan():
+					Expect(m).To(BeAssignableToTypeOf(completionMessage{}))
+				case <-time.After(500 * time.Millisecond):
+					Fail("timed out")
+				}
+				close(done)
+			}, 2.0)
+		})
+		Context("When OnConnected panics and the PanicCrash policy is set", func() {
+			It("should stop the server", func(done Done) {
+				server, err := NewServer(context.TODO(), SimpleHubFactory(&lifecyclePanicHub{}),
+					HubLifecyclePanicPolicy(PanicCrash))
+				Expect(err).NotTo(HaveOccurred())
+				conn := newTestingConnectionForServer()
+				go server.ServeConnection(conn)
+				select {
+				case <-server.context().Done():
+				case <-time.After(500 * time.Millisecond):
+					Fail("timed out")
+				}
+				close(done)
+			}, 2.0)
+		})
+		Context("When HubLifecyclePanicPolicy is used on a client", func() {
+			It("should return an error", func(done Done) {
+				_, err := NewClient(context.TODO(), newTestingConnection(), HubLifecyclePanicPolicy(PanicIgnore))
+				Expect(err).To(HaveOccurred())
+				close(done)
+			})
+		})
+	})
+
 	Describe("HTTPTransports option", func() {
 		Context("When HTTPTransports is one of WebSockets, ServerSentEvents or both", func() {
 			It("should set these transports", func(done Done) {
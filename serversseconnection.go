@@ -3,6 +3,7 @@ package signalr
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/rotisserie/eris"
 	"github.com/teivah/onecontext"
 	"io"
@@ -42,6 +43,18 @@ func newServerSSEConnection(parentContext context.Context, requestContext contex
 	return &s, nil
 }
 
+func (s *serverSSEConnection) setTransferFormat(format string) error {
+	return sseTransferFormat(format)
+}
+
+// sseTransferFormat returns an error if format can not be transferred with Server Sent Events
+func sseTransferFormat(format string) error {
+	if format != TransferFormatText {
+		return fmt.Errorf("transfer format %v is not supported by Server Sent Events", format)
+	}
+	return nil
+}
+
 func (s *serverSSEConnection) consumeRequest(request *http.Request) int {
 	if err := s.Context().Err(); err != nil {
 		return 410 // Gone
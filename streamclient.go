@@ -7,9 +7,10 @@ import (
 	"time"
 )
 
-func newStreamClient(chanReceiveTimeout time.Duration, streamBufferCapacity uint) *streamClient {
+func newStreamClient(protocol HubProtocol, chanReceiveTimeout time.Duration, streamBufferCapacity uint) *streamClient {
 	return &streamClient{
 		mx:                   sync.Mutex{},
+		protocol:             protocol,
 		upstreamChannels:     make(map[string]reflect.Value),
 		runningStreams:       make(map[string]bool),
 		chanReceiveTimeout:   chanReceiveTimeout,
@@ -19,6 +20,7 @@ func newStreamClient(chanReceiveTimeout time.Duration, streamBufferCapacity uint
 
 type streamClient struct {
 	mx                   sync.Mutex
+	protocol             HubProtocol
 	upstreamChannels     map[string]reflect.Value
 	runningStreams       map[string]bool
 	chanReceiveTimeout   time.Duration
@@ -64,6 +66,18 @@ func (c *streamClient) receiveStreamItem(streamItem streamItemMessage) error {
 	if upChan, ok := c.upstreamChannels[streamItem.InvocationID]; ok {
 		// Mark stream as running to detect illegal completion with result on this id
 		c.runningStreams[streamItem.InvocationID] = true
+		// Protocols which keep the item raw can decode it exactly into the channel type
+		if chanVal, ok, err := unmarshalValue(c.protocol, streamItem.Item, upChan.Type().Elem()); ok {
+			if err == nil {
+				return c.sendChanValSave(upChan, chanVal)
+			}
+			// Items which do not match the channel type exactly, e.g. numbers for chan string, are converted below
+			var item interface{}
+			if err := c.protocol.UnmarshalArgument(streamItem.Item, &item); err != nil {
+				return err
+			}
+			streamItem.Item = item
+		}
 		// Hack(?) for missing channel type information when the Protocol decodes StreamItem.Item
 		// Protocol specific, as only json has this inexact number type. Messagepack might cause different problems
 		chanElm := reflect.Indirect(reflect.New(upChan.Type().Elem())).Interface()
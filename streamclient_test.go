@@ -25,12 +25,16 @@ func (c *clientStreamHub) UploadStreamSmoke(upload1 <-chan int, factor float64,
 		case u1, ok1 = <-upload1:
 			if ok1 {
 				clientStreamingInvocationQueue <- fmt.Sprintf("u1: %v", u1)
+			} else {
+				// Don't receive from the closed channel again
+				upload1 = nil
 			}
 		case u2, ok2 = <-upload2:
 			if ok2 {
 				clientStreamingInvocationQueue <- fmt.Sprintf("u2: %v", u2)
+			} else {
+				upload2 = nil
 			}
-		default:
 		}
 		if !ok1 && !ok2 {
 			clientStreamingInvocationQueue <- "Finished"
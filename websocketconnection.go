@@ -1,28 +1,76 @@
 package signalr
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"github.com/rotisserie/eris"
 	"github.com/teivah/onecontext"
-	"golang.org/x/net/websocket"
+	"io"
+	"nhooyr.io/websocket"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
+// webSocketPolicy holds the server settings for WebSocket connections
+type webSocketPolicy struct {
+	pingInterval time.Duration
+}
+
+// webSocketCompression holds the settings for the permessage-deflate extension
+type webSocketCompression struct {
+	enabled         bool
+	contextTakeover bool
+	threshold       int
+}
+
+func (c *webSocketCompression) mode() websocket.CompressionMode {
+	switch {
+	case !c.enabled:
+		return websocket.CompressionDisabled
+	case c.contextTakeover:
+		return websocket.CompressionContextTakeover
+	default:
+		return websocket.CompressionNoContextTakeover
+	}
+}
+
 type webSocketConnection struct {
 	baseConnection
 	conn *websocket.Conn
+	// reader reads the current message. A message can be read with several calls to Read
+	reader io.Reader
+	// cancelReader cancels the context of reader
+	cancelReader context.CancelFunc
+	// messageType is the type of the written messages, depending on the TransferFormat of the HubProtocol
+	messageType websocket.MessageType
+	closeMx     sync.Mutex
+	closeCause  error
 }
 
 func newWebSocketConnection(parentContext context.Context, requestContext context.Context, connectionID string, conn *websocket.Conn) *webSocketConnection {
 	ctx, _ := onecontext.Merge(parentContext, requestContext)
 	w := &webSocketConnection{
-		conn: conn,
+		conn:        conn,
+		messageType: websocket.MessageText,
 		baseConnection: baseConnection{
 			ctx:          ctx,
 			connectionID: connectionID,
 		},
 	}
+	go func() {
+		<-ctx.Done()
+		// The connection is closed here and not by canceling reads or writes, which would drop
+		// the connection without close frame
+		if parentContext.Err() != nil {
+			_ = conn.Close(websocket.StatusGoingAway, "")
+			return
+		}
+		w.closeMx.Lock()
+		cause := w.closeCause
+		w.closeMx.Unlock()
+		_ = conn.Close(closeStatus(cause))
+	}()
 	return w
 }
 
@@ -30,24 +78,120 @@ func (w *webSocketConnection) Write(p []byte) (n int, err error) {
 	if err := w.Context().Err(); err != nil {
 		return 0, eris.Wrap(err, "webSocketConnection canceled")
 	}
+	ctx := context.Background()
 	if w.timeout > 0 {
-		defer func() { _ = w.conn.SetWriteDeadline(time.Time{}) }()
-		_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
 	}
-	return w.conn.Write(p)
+	if err = w.conn.Write(ctx, w.messageType, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
+// Read reads from the current message. When the current message is read completely, it waits for the next message.
+// Data which does not fit into p is returned by the following calls.
 func (w *webSocketConnection) Read(p []byte) (n int, err error) {
 	if err := w.Context().Err(); err != nil {
 		return 0, eris.Wrap(err, "webSocketConnection canceled")
 	}
-	if w.timeout > 0 {
-		defer func() { _ = w.conn.SetReadDeadline(time.Time{}) }()
-		_ = w.conn.SetReadDeadline(time.Now().Add(w.timeout))
+	for {
+		if w.reader == nil {
+			// The context must live until the message is read completely
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if w.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, w.timeout)
+			}
+			if _, w.reader, err = w.conn.Reader(ctx); err != nil {
+				cancel()
+				return 0, err
+			}
+			w.cancelReader = cancel
+		}
+		n, err = w.reader.Read(p)
+		if err == io.EOF {
+			w.reader = nil
+			w.cancelReader()
+			if n == 0 {
+				// Empty message
+				continue
+			}
+			return n, nil
+		}
+		return n, err
 	}
-	var data []byte
-	if err = websocket.Message.Receive(w.conn, &data); err != nil {
-		return 0, err
+}
+
+// setReadLimit sets the maximum size of a message. Larger messages close the connection with StatusMessageTooBig
+func (w *webSocketConnection) setReadLimit(limit int64) {
+	w.conn.SetReadLimit(limit)
+}
+
+func (w *webSocketConnection) setCloseCause(cause error) {
+	w.closeMx.Lock()
+	defer w.closeMx.Unlock()
+	w.closeCause = cause
+}
+
+// maxCloseReason is the maximum length of the reason in a close frame
+const maxCloseReason = 123
+
+// closeStatus returns the status code and reason of the close frame for the cause of closing a connection.
+// See RFC 6455, 7.4.1 Defined Status Codes
+func closeStatus(cause error) (websocket.StatusCode, string) {
+	if cause == nil || errors.Is(cause, context.Canceled) {
+		return websocket.StatusNormalClosure, ""
+	}
+	var tooLarge *messageTooLargeError
+	var handshake *handshakeError
+	var admission *admissionError
+	var limit *invocationLimitError
+	var queueFull *outboundQueueFullError
+	status := websocket.StatusInternalError
+	switch {
+	case errors.As(cause, &tooLarge):
+		status = websocket.StatusMessageTooBig
+	case errors.As(cause, &handshake), errors.As(cause, &admission), errors.As(cause, &limit), errors.As(cause, &queueFull):
+		status = websocket.StatusPolicyViolation
+	}
+	reason := cause.Error()
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+		// Do not cut a character in two
+		for !utf8.ValidString(reason) {
+			reason = reason[:len(reason)-1]
+		}
+	}
+	return status, reason
+}
+
+// setTransferFormat sends the messages as binary messages with TransferFormatBinary, else as text messages
+func (w *webSocketConnection) setTransferFormat(format string) error {
+	if format == TransferFormatBinary {
+		w.messageType = websocket.MessageBinary
+	} else {
+		w.messageType = websocket.MessageText
+	}
+	return nil
+}
+
+// keepAlive sends ping frames in interval until ctx is done.
+// If the other side does not answer with a pong frame in interval, the connection is closed.
+func (w *webSocketConnection) keepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := w.conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
 	}
-	return bytes.NewReader(data).Read(p)
 }
@@ -0,0 +1,242 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-kit/kit/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// dialHandshake dials a WebSocket connection to url and sends the json handshake
+func dialHandshake(url string) *websocket.Conn {
+	ws, _, err := websocket.Dial(context.TODO(), url, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ws.Write(context.TODO(), websocket.MessageText, []byte("{\"protocol\":\"json\",\"version\":1}\u001e"))).To(Succeed())
+	return ws
+}
+
+// readUntilError reads from ws until an error occurs
+func readUntilError(ws *websocket.Conn) error {
+	for {
+		if _, _, err := ws.Read(context.TODO()); err != nil {
+			return err
+		}
+	}
+}
+
+var _ = Describe("WebSocket connections", func() {
+	Context("When messages are read with a buffer smaller than the message", func() {
+		It("should return the messages completely over several reads", func(done Done) {
+			read := make(chan []byte, 1)
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ws, err := websocket.Accept(w, r, nil)
+				if err != nil {
+					return
+				}
+				conn := newWebSocketConnection(context.TODO(), r.Context(), "test", ws)
+				var data []byte
+				p := make([]byte, 7)
+				for len(data) < 250 {
+					n, err := conn.Read(p)
+					if err != nil {
+						break
+					}
+					data = append(data, p[:n]...)
+				}
+				read <- data
+			}))
+			defer httpServer.Close()
+			ws, _, err := websocket.Dial(context.TODO(), httpServer.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			first := bytes.Repeat([]byte("a"), 100)
+			second := bytes.Repeat([]byte("b"), 150)
+			Expect(ws.Write(context.TODO(), websocket.MessageText, first)).To(Succeed())
+			Expect(ws.Write(context.TODO(), websocket.MessageText, second)).To(Succeed())
+			Expect(<-read).To(Equal(append(first, second...)))
+			_ = ws.Close(websocket.StatusNormalClosure, "")
+			close(done)
+		}, 2.0)
+	})
+	Context("When a message exceeds the read limit", func() {
+		It("should close the connection with StatusMessageTooBig", func(done Done) {
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ws, err := websocket.Accept(w, r, nil)
+				if err != nil {
+					return
+				}
+				conn := newWebSocketConnection(context.TODO(), r.Context(), "test", ws)
+				conn.setReadLimit(100)
+				_, _ = ioutil.ReadAll(conn)
+			}))
+			defer httpServer.Close()
+			ws, _, err := websocket.Dial(context.TODO(), httpServer.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Write(context.TODO(), websocket.MessageText, bytes.Repeat([]byte("a"), 200))).To(Succeed())
+			Expect(websocket.CloseStatus(readUntilError(ws))).To(Equal(websocket.StatusMessageTooBig))
+			close(done)
+		}, 2.0)
+	})
+	Context("When a server is used over WebSockets", func() {
+		var httpServer *httptest.Server
+		var cancel context.CancelFunc
+		start := func(options ...func(Party) error) string {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			server, err := NewServer(ctx, append([]func(Party) error{SimpleHubFactory(&addHub{}),
+				Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false)}, options...)...)
+			Expect(err).NotTo(HaveOccurred())
+			httpServer = httptest.NewServer(server.Handler())
+			return httpServer.URL
+		}
+		AfterEach(func() {
+			cancel()
+			httpServer.Close()
+		})
+		It("should close with a close message when a message exceeds the MaximumReceiveMessageSize", func(done Done) {
+			ws := dialHandshake(start(MaximumReceiveMessageSize(100)))
+			message := `{"type":1,"target":"add2","arguments":["` + strings.Repeat("x", 200) + `"]}` + "\u001e"
+			Expect(ws.Write(context.TODO(), websocket.MessageText, []byte(message))).To(Succeed())
+			var messages []string
+			err := func() error {
+				for {
+					_, data, err := ws.Read(context.TODO())
+					if err != nil {
+						return err
+					}
+					messages = append(messages, string(data))
+				}
+			}()
+			Expect(websocket.CloseStatus(err)).To(Equal(websocket.StatusMessageTooBig))
+			Expect(messages[len(messages)-1]).To(ContainSubstring(`"type":7`))
+			Expect(messages[len(messages)-1]).To(ContainSubstring(`"error"`))
+			close(done)
+		}, 2.0)
+		It("should close with StatusNormalClosure when the client sends a close message", func(done Done) {
+			ws := dialHandshake(start())
+			_, _, err := ws.Read(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Write(context.TODO(), websocket.MessageText, []byte("{\"type\":7}\u001e"))).To(Succeed())
+			Expect(websocket.CloseStatus(readUntilError(ws))).To(Equal(websocket.StatusNormalClosure))
+			close(done)
+		}, 2.0)
+		It("should close with StatusPolicyViolation when the handshake is rejected", func(done Done) {
+			ws := dialHandshake(start(AcceptHandshake(func(Handshake) error {
+				return errors.New("not welcome")
+			})))
+			err := readUntilError(ws)
+			Expect(websocket.CloseStatus(err)).To(Equal(websocket.StatusPolicyViolation))
+			var closeErr websocket.CloseError
+			Expect(errors.As(err, &closeErr)).To(BeTrue())
+			Expect(closeErr.Reason).To(Equal("not welcome"))
+			close(done)
+		}, 2.0)
+		It("should close with StatusInternalError when the hub fails", func(done Done) {
+			ws := dialHandshake(start(SimpleHubFactory(&lifecyclePanicHub{})))
+			Expect(websocket.CloseStatus(readUntilError(ws))).To(Equal(websocket.StatusInternalError))
+			close(done)
+		}, 2.0)
+		It("should answer an unknown connection token with 404 before upgrading", func(done Done) {
+			_, resp, err := websocket.Dial(context.TODO(), start()+"?id=unknown", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(404))
+			close(done)
+		}, 2.0)
+		It("should close the connection with StatusGoingAway when the server stops", func(done Done) {
+			ws := dialHandshake(start())
+			// Wait for the handshake response, so the connection is served
+			_, _, err := ws.Read(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			cancel()
+			Expect(websocket.CloseStatus(readUntilError(ws))).To(Equal(websocket.StatusGoingAway))
+			close(done)
+		}, 2.0)
+		Context("When WebSocketPingInterval is set", func() {
+			It("should keep connections which answer pings and close connections which do not", func(done Done) {
+				url := start(WebSocketPingInterval(200 * time.Millisecond))
+				// A reading connection answers pings
+				answering := dialHandshake(url)
+				received := make(chan []byte, 10)
+				go func() {
+					for {
+						_, data, err := answering.Read(context.TODO())
+						if err != nil {
+							close(received)
+							return
+						}
+						received <- data
+					}
+				}()
+				<-received // Handshake response
+				// A connection which is not read does not answer pings
+				silent := dialHandshake(url)
+				time.Sleep(700 * time.Millisecond)
+				Expect(answering.Write(context.TODO(), websocket.MessageText,
+					[]byte("{\"type\":1,\"invocationId\":\"1\",\"target\":\"add2\",\"arguments\":[1]}\u001e"))).To(Succeed())
+				Expect(string(<-received)).To(ContainSubstring(`"result":3`))
+				Expect(readUntilError(silent)).To(HaveOccurred())
+				close(done)
+			}, 3.0)
+		})
+		Context("When WebSocketCompression is set", func() {
+			It("should accept compression offered by the client", func(done Done) {
+				_, resp, err := websocket.Dial(context.TODO(), start(WebSocketCompression(false)), &websocket.DialOptions{
+					CompressionMode: websocket.CompressionNoContextTakeover,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Header.Get("Sec-WebSocket-Extensions")).To(HavePrefix("permessage-deflate"))
+				close(done)
+			}, 2.0)
+			It("should offer compression from NewHTTPClient and transfer compressed messages", func(done Done) {
+				url := start(WebSocketCompression(true))
+				offered := make(chan string, 1)
+				handler := httpServer.Config.Handler
+				httpServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Upgrade") != "" {
+						offered <- r.Header.Get("Sec-WebSocket-Extensions")
+					}
+					handler.ServeHTTP(w, r)
+				})
+				client, err := NewHTTPClient(context.TODO(), url, WebSocketCompression(true), WebSocketCompressionThreshold(64),
+					Logger(&nonProtocolLogger{log.NewLogfmtLogger(os.Stderr)}, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(<-offered).To(Equal("permessage-deflate"))
+				Expect(client.Start()).To(Succeed())
+				message := strings.Repeat("telemetry", 1000)
+				result := <-client.Invoke("Echo", message)
+				Expect(result.Error).NotTo(HaveOccurred())
+				Expect(result.Value).To(Equal(message))
+				Expect(client.Stop()).To(Succeed())
+				close(done)
+			}, 2.0)
+		})
+		It("should not accept compression when WebSocketCompression is not set", func(done Done) {
+			_, resp, err := websocket.Dial(context.TODO(), start(), &websocket.DialOptions{
+				CompressionMode: websocket.CompressionNoContextTakeover,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Header.Get("Sec-WebSocket-Extensions")).To(BeEmpty())
+			close(done)
+		}, 2.0)
+	})
+	Context("When the reason of a close frame is too long", func() {
+		It("should cut it without cutting a character", func() {
+			status, reason := closeStatus(&messageTooLargeError{size: 1 << 20, maximumSize: 1 << 10})
+			Expect(status).To(Equal(websocket.StatusMessageTooBig))
+			Expect(reason).To(ContainSubstring("exceeds the maximum receive message size"))
+			status, reason = closeStatus(errors.New(strings.Repeat("ä", 100)))
+			Expect(status).To(Equal(websocket.StatusInternalError))
+			Expect(len(reason)).To(Equal(122))
+			Expect(utf8.ValidString(reason)).To(BeTrue())
+		})
+	})
+})